@@ -1,20 +1,39 @@
 package structures
 
 import (
+	"fmt"
+	"sync"
 	"time"
 )
 
 type Balancer[V comparable] struct {
-	cll            CircularLinkedList[V]
-	stats          *SafeMap[V, *BalancerStats]
+	*KeyBalancer[V, V]
+}
+
+// KeyBalancer rotates values of any type. Values are identified by the key
+// returned from the key func, so stats, Remove and Report are keyed by K.
+// The rotation and stats are guarded by mu, which is never held while the
+// store is accessed or OnReportRemove runs.
+type KeyBalancer[K comparable, V any] struct {
+	cll            DoublyCircularLinkedList[K]
+	nodes          map[K]*DoublyNode[K]
+	vals           *SafeMap[K, V]
+	stats          *SafeMap[K, *BalancerStats]
+	reported       map[K]struct{}
+	key            func(V) K
 	readyEventCh   chan BalancerResp[V]
 	onReportRemove func(V)
+	onStoreErr     func(error)
+	mu             sync.Mutex
 	*BalancerOpts
 }
 
 type BalancerStats struct {
-	errors   int
-	lastUsed time.Time
+	errors           int
+	lastUsed         time.Time
+	limit            float64
+	inFlight         int
+	quarantinedUntil time.Time
 }
 
 func (b BalancerStats) Errors() int {
@@ -25,24 +44,65 @@ func (b BalancerStats) LastUsed() time.Time {
 	return b.lastUsed
 }
 
-type BalancerResp[V comparable] struct {
-	Data   func() V
-	Use    func()
-	Report func()
-	Wait   func()
+// QuarantinedUntil returns the time until which the value is skipped by
+// Use and Acquire. It is zero if the value was never quarantined.
+func (b BalancerStats) QuarantinedUntil() time.Time {
+	return b.quarantinedUntil
+}
+
+// Limit returns the current adaptive concurrency limit or -1 if the
+// balancer has no adaptive limit.
+func (b BalancerStats) Limit() int {
+	if b.limit == 0 {
+		return -1
+	}
+	return int(b.limit)
+}
+
+// InFlight returns the number of acquired responses not yet released.
+func (b BalancerStats) InFlight() int {
+	return b.inFlight
+}
+
+type BalancerResp[V any] struct {
+	Data    func() V
+	Use     func()
+	Report  func()
+	Wait    func()
+	Success func()
+	Timeout func()
 }
 
 type BalancerOpts struct {
-	MaxErrs    int
-	UseTimeout *time.Duration
+	MaxErrs       int
+	UseTimeout    *time.Duration
+	AdaptiveLimit *AdaptiveLimit
+	Quarantine    *time.Duration
+	Store         BalancerStore
+	Clock         Clock
+}
+
+// AdaptiveLimit configures AIMD concurrency limits per value. The limit
+// starts at Initial, grows by Increase on every success and is multiplied by
+// Decrease on every error or timeout, staying within [Min, Max].
+type AdaptiveLimit struct {
+	Initial  int
+	Min      int
+	Max      int
+	Increase float64
+	Decrease float64
 }
 
 type BalancerOpt func(*BalancerOpts)
 
 func DefaultBalancerOpts() *BalancerOpts {
 	return &BalancerOpts{
-		MaxErrs:    -1,
-		UseTimeout: nil,
+		MaxErrs:       -1,
+		UseTimeout:    nil,
+		AdaptiveLimit: nil,
+		Quarantine:    nil,
+		Store:         nil,
+		Clock:         RealClock(),
 	}
 }
 
@@ -58,128 +118,504 @@ func UseTimeoutBalancerOpt(useTimeout time.Duration) BalancerOpt {
 	}
 }
 
+// QuarantineBalancerOpt makes values that reach MaxErrs sit out for the
+// given duration instead of being removed. Their error count is reset.
+func QuarantineBalancerOpt(quarantine time.Duration) BalancerOpt {
+	return func(opts *BalancerOpts) {
+		opts.Quarantine = &quarantine
+	}
+}
+
+// StoreBalancerOpt shares last used times, error counts and quarantines
+// through store, e.g. with balancers in other processes. Values are stored
+// under fmt.Sprint(key).
+func StoreBalancerOpt(store BalancerStore) BalancerOpt {
+	return func(opts *BalancerOpts) {
+		opts.Store = store
+	}
+}
+
+func ClockBalancerOpt(clock Clock) BalancerOpt {
+	return func(opts *BalancerOpts) {
+		opts.Clock = clock
+	}
+}
+
+// AdaptiveLimitBalancerOpt enables adaptive concurrency for Acquire. A zero
+// Increase defaults to 1 and a zero Decrease defaults to 0.5.
+func AdaptiveLimitBalancerOpt(limit AdaptiveLimit) BalancerOpt {
+	return func(opts *BalancerOpts) {
+		if limit.Increase == 0 {
+			limit.Increase = 1
+		}
+		if limit.Decrease == 0 {
+			limit.Decrease = 0.5
+		}
+		limit.Min = max(limit.Min, 1)
+		if limit.Max < limit.Min {
+			limit.Max = limit.Min
+		}
+		limit.Initial = min(max(limit.Initial, limit.Min), limit.Max)
+		opts.AdaptiveLimit = &limit
+	}
+}
+
 func NewBalancer[V comparable](opts ...BalancerOpt) *Balancer[V] {
+	return &Balancer[V]{
+		KeyBalancer: NewKeyBalancer(func(v V) V { return v }, opts...),
+	}
+}
+
+func NewKeyBalancer[K comparable, V any](key func(V) K, opts ...BalancerOpt) *KeyBalancer[K, V] {
 	o := DefaultBalancerOpts()
 	for _, opt := range opts {
 		opt(o)
 	}
-	return &Balancer[V]{
-		cll:          NewCircularLinkedList[V](),
-		stats:        NewSafeMap[V, *BalancerStats](),
+	b := &KeyBalancer[K, V]{
+		cll:          NewDoublyCircularLinkedList[K](),
+		nodes:        map[K]*DoublyNode[K]{},
+		reported:     map[K]struct{}{},
+		vals:         NewSafeMap[K, V](),
+		stats:        NewSafeMap[K, *BalancerStats](),
+		key:          key,
 		BalancerOpts: o,
 		readyEventCh: make(chan BalancerResp[V]),
 	}
+	// Node handles never leave the balancer, so removed nodes can be reused
+	b.cll.SetNodePool(NewNodePool[K]())
+	return b
 }
 
 func (b *Balancer[V]) SetOnReportRemove(fn func(V)) *Balancer[V] {
+	b.KeyBalancer.SetOnReportRemove(fn)
+	return b
+}
+
+func (b *KeyBalancer[K, V]) SetOnReportRemove(fn func(V)) *KeyBalancer[K, V] {
 	b.onReportRemove = fn
 	return b
 }
 
-func (b *Balancer[V]) OnReportRemove() func(V) {
+func (b *KeyBalancer[K, V]) OnReportRemove() func(V) {
 	return b.onReportRemove
 }
 
-func (b Balancer[V]) ReadyEventCh() <-chan BalancerResp[V] {
+func (b *Balancer[V]) SetOnStoreErr(fn func(error)) *Balancer[V] {
+	b.KeyBalancer.SetOnStoreErr(fn)
+	return b
+}
+
+// SetOnStoreErr sets the func called when the store fails. The balancer
+// keeps going with its local stats.
+func (b *KeyBalancer[K, V]) SetOnStoreErr(fn func(error)) *KeyBalancer[K, V] {
+	b.onStoreErr = fn
+	return b
+}
+
+func (b *KeyBalancer[K, V]) OnStoreErr() func(error) {
+	return b.onStoreErr
+}
+
+func (b *KeyBalancer[K, V]) ReadyEventCh() <-chan BalancerResp[V] {
 	return b.readyEventCh
 }
 
-func (b *Balancer[V]) Add(vals ...V) {
+// Add adds the values to the front of the rotation, keeping their order.
+// Values whose key is already present are moved and get fresh stats. If this
+// balancer removed a value for reaching MaxErrs, its stored errors are
+// cleared; errors reported by other balancers are kept, see Reset.
+func (b *KeyBalancer[K, V]) Add(vals ...V) {
+	b.resetStored(b.takeReported(vals)...)
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	for i := len(vals) - 1; i >= 0; i-- {
 		val := vals[i]
-		b.cll.AddFirst(val)
-		stats := &BalancerStats{}
-		b.stats.Set(val, stats)
+		key := b.key(val)
+		if n, ok := b.nodes[key]; ok {
+			b.cll.MoveToFront(n)
+		} else {
+			b.nodes[key] = b.cll.AddFirst(key)
+		}
+		b.vals.Set(key, val)
+		b.stats.Set(key, b.newStats())
 	}
 }
 
-func (b *Balancer[V]) AddLast(vals ...V) {
+// AddLast adds the values to the back of the rotation. Values whose key is
+// already present are moved and get fresh stats, like with Add.
+func (b *KeyBalancer[K, V]) AddLast(vals ...V) {
+	b.resetStored(b.takeReported(vals)...)
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	for _, val := range vals {
-		b.cll.AddLast(val)
-		stats := &BalancerStats{}
-		b.stats.Set(val, stats)
+		key := b.key(val)
+		if n, ok := b.nodes[key]; ok {
+			b.cll.MoveToBack(n)
+		} else {
+			b.nodes[key] = b.cll.AddLast(key)
+		}
+		b.vals.Set(key, val)
+		b.stats.Set(key, b.newStats())
 	}
 }
 
-func (b *Balancer[V]) Remove(vals ...V) {
-	for _, val := range vals {
-		b.cll.Remove(val)
-		b.stats.Delete(val)
+// Remove removes the values with the given keys.
+// time-complexity: O(1) per key
+func (b *KeyBalancer[K, V]) Remove(keys ...K) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		b.remove(key)
+	}
+}
+
+// Reset clears the errors and quarantine of the values with the given keys,
+// in the store too, so every balancer sharing it uses them again.
+func (b *KeyBalancer[K, V]) Reset(keys ...K) {
+	b.resetStored(keys...)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		delete(b.reported, key)
+		if stats, ok := b.stats.Get(key); ok {
+			stats.errors = 0
+			stats.quarantinedUntil = time.Time{}
+		}
+	}
+}
+
+func (b *KeyBalancer[K, V]) Use() (resp BalancerResp[V], ok bool) {
+	return b.next(false)
+}
+
+// Acquire works like Use but skips values that are at their adaptive
+// concurrency limit. The returned response holds a slot until one of
+// Success, Report or Timeout is called. It returns false if every value is
+// at its limit.
+func (b *KeyBalancer[K, V]) Acquire() (resp BalancerResp[V], ok bool) {
+	return b.next(true)
+}
+
+// Stats returns a snapshot of the stats of the value with key.
+func (b *KeyBalancer[K, V]) Stats(key K) (stats *BalancerStats, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats, ok = b.stats.Get(key)
+	if !ok {
+		return nil, false
+	}
+	snapshot := *stats
+	return &snapshot, true
+}
+
+func (b *KeyBalancer[K, V]) Get(key K) (val V, ok bool) {
+	return b.vals.Get(key)
+}
+
+func (b *KeyBalancer[K, V]) Keys() (keys []K) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cll.Vals()
+}
+
+func (b *KeyBalancer[K, V]) Vals() (vals []V) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range b.cll.Vals() {
+		vals = append(vals, b.vals.MustGet(key))
 	}
+	return
+}
+
+func (b *KeyBalancer[K, V]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cll.Len()
 }
 
-func (b *Balancer[V]) Use() (resp BalancerResp[V], ok bool) {
-	resp = BalancerResp[V]{
+func (b *KeyBalancer[K, V]) Peek() (val V, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key, ok := b.cll.First()
+	if !ok {
+		return
+	}
+	return b.vals.Get(key)
+}
+
+func (b *KeyBalancer[K, V]) Last() (val V, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key, ok := b.cll.Last()
+	if !ok {
+		return
+	}
+	return b.vals.Get(key)
+}
+
+func emptyBalancerResp[V any]() BalancerResp[V] {
+	return BalancerResp[V]{
 		Use: func() {},
 		Data: func() V {
 			var v V
 			return v
 		},
-		Report: func() {},
-		Wait:   func() {},
+		Report:  func() {},
+		Wait:    func() {},
+		Success: func() {},
+		Timeout: func() {},
 	}
+}
 
-	// Grab the first value
-	var data V
-	data, ok = b.cll.First()
-	if !ok {
-		return
+func (b *KeyBalancer[K, V]) newStats() *BalancerStats {
+	stats := &BalancerStats{}
+	if b.AdaptiveLimit != nil {
+		stats.limit = float64(b.AdaptiveLimit.Initial)
 	}
+	return stats
+}
 
-	// Get the stats for the value
-	var stats *BalancerStats
-	stats, ok = b.stats.Get(data)
-	if !ok {
+// next rotates through the values until one can be handed out, taking an
+// adaptive concurrency slot if acquire is set. mu is released while the
+// store is read and while OnReportRemove runs, so both may call back into
+// the balancer.
+func (b *KeyBalancer[K, V]) next(acquire bool) (resp BalancerResp[V], ok bool) {
+	for range b.Len() {
+		b.mu.Lock()
+		key, found := b.cll.First()
+		if !found {
+			b.mu.Unlock()
+			break
+		}
+		b.cll.Rotate()
+		b.mu.Unlock()
+
+		state, synced := b.load(key)
+
+		b.mu.Lock()
+		data, _ := b.vals.Get(key)
+		stats, found := b.stats.Get(key)
+		if !found {
+			// Removed while the store was read
+			b.mu.Unlock()
+			continue
+		}
+		if synced {
+			stats.lastUsed = state.LastUsed
+			stats.errors = state.Errors
+			stats.quarantinedUntil = state.QuarantinedUntil
+			// Reached MaxErrs in another balancer sharing the store
+			if b.Quarantine == nil && b.MaxErrs != -1 && stats.errors > b.MaxErrs {
+				b.remove(key)
+				b.mu.Unlock()
+				b.reportRemoved(data)
+				continue
+			}
+		}
+		if b.Clock.Now().Before(stats.quarantinedUntil) ||
+			(acquire && b.AdaptiveLimit != nil && stats.inFlight >= int(stats.limit)) {
+			b.mu.Unlock()
+			continue
+		}
+		if acquire {
+			stats.inFlight++
+		}
+		b.mu.Unlock()
+		return b.newBalancerResp(key, data, stats, acquire), true
+	}
+	return emptyBalancerResp[V](), false
+}
+
+// load reads the state stored for key. It returns false without a store or
+// if the store failed.
+func (b *KeyBalancer[K, V]) load(key K) (state BalancerState, ok bool) {
+	if b.Store == nil {
+		return state, false
+	}
+	state, err := b.Store.Load(fmt.Sprint(key))
+	if err != nil {
+		b.storeErr(err)
+		return state, false
+	}
+	return state, true
+}
+
+// takeReported returns the keys of vals that this balancer removed for
+// reaching MaxErrs and forgets them.
+func (b *KeyBalancer[K, V]) takeReported(vals []V) (keys []K) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, val := range vals {
+		key := b.key(val)
+		if _, ok := b.reported[key]; ok {
+			delete(b.reported, key)
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// resetStored clears the errors and quarantine stored for keys.
+func (b *KeyBalancer[K, V]) resetStored(keys ...K) {
+	if b.Store == nil {
 		return
 	}
+	for _, key := range keys {
+		err := b.Store.Update(fmt.Sprint(key), func(state *BalancerState) {
+			state.Errors = 0
+			state.QuarantinedUntil = time.Time{}
+		})
+		if err != nil {
+			b.storeErr(err)
+		}
+	}
+}
 
-	// Rotate the list
-	b.cll.Rotate()
+// remove drops key from the rotation. It must be called with mu held.
+func (b *KeyBalancer[K, V]) remove(key K) {
+	if n, ok := b.nodes[key]; ok {
+		b.cll.Remove(n)
+		delete(b.nodes, key)
+	}
+	b.vals.Delete(key)
+	b.stats.Delete(key)
+}
 
-	return b.newBalancerResp(data, stats), ok
+// report counts an error and quarantines the value if it reached MaxErrs.
+// It returns true if the value must be removed instead.
+func (b *KeyBalancer[K, V]) report(key K, stats *BalancerStats) (remove bool) {
+	apply := func(errors *int, quarantinedUntil *time.Time) {
+		*errors++
+		if b.MaxErrs == -1 || *errors <= b.MaxErrs {
+			return
+		}
+		if b.Quarantine == nil {
+			remove = true
+			return
+		}
+		*errors = 0
+		*quarantinedUntil = b.Clock.Now().Add(*b.Quarantine)
+	}
+	if b.Store != nil {
+		var stored BalancerState
+		err := b.Store.Update(fmt.Sprint(key), func(state *BalancerState) {
+			remove = false
+			apply(&state.Errors, &state.QuarantinedUntil)
+			stored = *state
+		})
+		if err == nil {
+			b.mu.Lock()
+			stats.errors = stored.Errors
+			stats.quarantinedUntil = stored.QuarantinedUntil
+			b.mu.Unlock()
+			return
+		}
+		b.storeErr(err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	apply(&stats.errors, &stats.quarantinedUntil)
+	return
 }
 
-func (b *Balancer[V]) Stats(val V) (stats *BalancerStats, ok bool) {
-	return b.stats.Get(val)
+func (b *KeyBalancer[K, V]) use(key K, stats *BalancerStats) {
+	now := b.Clock.Now()
+	b.mu.Lock()
+	stats.lastUsed = now
+	b.mu.Unlock()
+	if b.Store != nil {
+		err := b.Store.Update(fmt.Sprint(key), func(state *BalancerState) {
+			state.LastUsed = now
+		})
+		if err != nil {
+			b.storeErr(err)
+		}
+	}
 }
 
-func (b *Balancer[V]) Vals() (vals []V) {
-	return b.cll.Vals()
+// removeReported removes the value a response was handed out for, unless it
+// was removed or added again since.
+func (b *KeyBalancer[K, V]) removeReported(key K, data V, stats *BalancerStats) {
+	b.mu.Lock()
+	current, ok := b.stats.Get(key)
+	if !ok || current != stats {
+		b.mu.Unlock()
+		return
+	}
+	b.remove(key)
+	b.reported[key] = struct{}{}
+	b.mu.Unlock()
+	b.reportRemoved(data)
+}
+
+func (b *KeyBalancer[K, V]) reportRemoved(data V) {
+	if b.OnReportRemove() != nil {
+		b.OnReportRemove()(data)
+	}
 }
 
-func (b *Balancer[V]) Len() int {
-	return b.cll.Size
+func (b *KeyBalancer[K, V]) storeErr(err error) {
+	if b.OnStoreErr() != nil {
+		b.OnStoreErr()(err)
+	}
 }
 
-func (b *Balancer[V]) Peek() (val V, ok bool) {
-	return b.cll.First()
+// balancerLease tracks whether a response still holds a slot and whether
+// its outcome was already counted.
+type balancerLease struct {
+	acquired bool
+	released bool
 }
 
-func (b *Balancer[V]) Last() (val V, ok bool) {
-	return b.cll.Last()
+// release adjusts the adaptive limit after a request finished and frees the
+// acquired slot if there is one. Only the first call per response counts.
+func (b *KeyBalancer[K, V]) release(stats *BalancerStats, lease *balancerLease, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lease.released {
+		return
+	}
+	lease.released = true
+	if lease.acquired {
+		stats.inFlight--
+	}
+	if l := b.AdaptiveLimit; l != nil {
+		if success {
+			stats.limit = min(stats.limit+l.Increase, float64(l.Max))
+		} else {
+			stats.limit = max(stats.limit*l.Decrease, float64(l.Min))
+		}
+	}
 }
 
-func (b *Balancer[V]) newBalancerResp(data V, stats *BalancerStats) BalancerResp[V] {
+func (b *KeyBalancer[K, V]) newBalancerResp(key K, data V, stats *BalancerStats, acquired bool) BalancerResp[V] {
+	lease := &balancerLease{acquired: acquired}
 	return BalancerResp[V]{
 		Use: func() {
-			stats.lastUsed = time.Now()
+			b.use(key, stats)
 		},
 		Data: func() V {
 			return data
 		},
 		Report: func() {
-			stats.errors++
-			if b.MaxErrs != -1 && stats.errors > b.MaxErrs {
-				b.Remove(data)
-				if b.OnReportRemove() != nil {
-					b.OnReportRemove()(data)
-				}
+			b.release(stats, lease, false)
+			if b.report(key, stats) {
+				b.removeReported(key, data, stats)
 			}
 		},
 		Wait: func() {
-			if b.UseTimeout != nil && !stats.lastUsed.IsZero() {
-				time.Sleep(*b.UseTimeout - time.Since(stats.lastUsed))
+			b.mu.Lock()
+			lastUsed := stats.lastUsed
+			b.mu.Unlock()
+			if b.UseTimeout != nil && !lastUsed.IsZero() {
+				b.Clock.Sleep(*b.UseTimeout - b.Clock.Now().Sub(lastUsed))
 			}
 		},
+		Success: func() {
+			b.release(stats, lease, true)
+		},
+		Timeout: func() {
+			b.release(stats, lease, false)
+		},
 	}
 }
@@ -0,0 +1,187 @@
+package structures
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BalancerStore holds balancer state that is shared between balancers.
+type BalancerStore interface {
+	// Load returns the state stored under key, or the zero state.
+	Load(key string) (BalancerState, error)
+	// Update atomically modifies the state stored under key.
+	Update(key string, fn func(*BalancerState)) error
+}
+
+type BalancerState struct {
+	LastUsed         time.Time `json:"lastUsed"`
+	Errors           int       `json:"errors"`
+	QuarantinedUntil time.Time `json:"quarantinedUntil"`
+}
+
+// FileBalancerStore keeps balancer state in a JSON file, so balancers in
+// several processes on one machine can share it. Updates are serialized by
+// flock on a lock file next to it and replace the file with a rename, so
+// readers never see a partial write. Load keeps the parsed file and only
+// re-reads it after another update replaced it, which costs one stat per
+// call. Update always re-reads the file under the lock.
+type FileBalancerStore struct {
+	path   string
+	lock   string
+	mu     sync.Mutex
+	states map[string]BalancerState
+	info   fs.FileInfo
+}
+
+func NewFileBalancerStore(path string) (*FileBalancerStore, error) {
+	s := &FileBalancerStore{
+		path: path,
+		lock: path + ".lock",
+	}
+	// Fail early on platforms without file locking
+	if err := s.withLock(func() error { return nil }); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileBalancerStore) Load(key string) (state BalancerState, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return state, err
+	}
+	return s.states[key], nil
+}
+
+func (s *FileBalancerStore) Update(key string, fn func(*BalancerState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.withLock(func() error {
+		if err := s.read(); err != nil {
+			return err
+		}
+		states := maps.Clone(s.states)
+		state := states[key]
+		fn(&state)
+		states[key] = state
+
+		data, err := json.Marshal(states)
+		if err != nil {
+			return err
+		}
+		info, err := s.write(data)
+		if err != nil {
+			return err
+		}
+		s.states, s.info = states, info
+		return nil
+	})
+}
+
+// withLock holds an exclusive flock on the lock file while fn runs.
+func (s *FileBalancerStore) withLock(fn func() error) error {
+	f, err := os.OpenFile(s.lock, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f, true); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+	return fn()
+}
+
+// refresh re-reads the store file if it was replaced since it was last read.
+// s.mu must be held.
+func (s *FileBalancerStore) refresh() error {
+	info, err := os.Stat(s.path)
+	if err == nil && s.info != nil && os.SameFile(s.info, info) &&
+		s.info.ModTime().Equal(info.ModTime()) && s.info.Size() == info.Size() {
+		return nil
+	}
+	return s.read()
+}
+
+// read reads the store file. A missing file holds no state. s.mu must be
+// held.
+func (s *FileBalancerStore) read() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.states, s.info = map[string]BalancerState{}, nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Stat the opened file, the path may have been replaced since
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	states, err := readBalancerStates(f)
+	if err != nil {
+		return err
+	}
+	s.states, s.info = states, info
+	return nil
+}
+
+// write replaces the store file with data through a temp file in the same
+// directory and returns the info of the new file.
+func (s *FileBalancerStore) write(data []byte) (info fs.FileInfo, err error) {
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return nil, err
+	}
+	if err = f.Chmod(0o644); err != nil {
+		return nil, err
+	}
+	if info, err = f.Stat(); err != nil {
+		return nil, err
+	}
+	if err = f.Close(); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(f.Name(), s.path); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func readBalancerStates(f *os.File) (map[string]BalancerState, error) {
+	states := map[string]BalancerState{}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return states, nil
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+	if states == nil {
+		// The file held null
+		states = map[string]BalancerState{}
+	}
+	return states, nil
+}
@@ -1,8 +1,12 @@
 package structures_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -28,18 +32,19 @@ func TestPeek(t *testing.T) {
 }
 
 func TestUse(t *testing.T) {
+	clock := structures.NewFakeClock(time.Now())
 	balancer := structures.NewBalancer[int](
-		structures.UseTimeoutBalancerOpt(1 * time.Second),
+		structures.UseTimeoutBalancerOpt(1*time.Second),
+		structures.ClockBalancerOpt(clock),
 	)
 	balancer.Add(1)
-	after := time.Now()
+	after := clock.Now()
 	res, ok := balancer.Use()
 	if !ok {
 		t.Errorf("expected true, got false")
 	}
 	res.Wait()
 	res.Use()
-	time.Sleep(1 * time.Second)
 	stats, ok := balancer.Stats(res.Data())
 	if !ok {
 		t.Errorf("expected true, got false")
@@ -47,6 +52,26 @@ func TestUse(t *testing.T) {
 	if stats.LastUsed().Before(after) {
 		t.Errorf("expected after, got before")
 	}
+
+	// The next use has to wait out the remaining timeout
+	clock.Advance(400 * time.Millisecond)
+	res, _ = balancer.Use()
+	waited := make(chan struct{})
+	go func() {
+		res.Wait()
+		close(waited)
+	}()
+	for clock.PendingTimers() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(599 * time.Millisecond)
+	select {
+	case <-waited:
+		t.Errorf("expected wait to block until the timeout passed")
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	<-waited
 }
 
 func TestRemove(t *testing.T) {
@@ -91,3 +116,332 @@ func TestBalancerReadyEvents(t *testing.T) {
 	balancer.Add(1, 2, 3)
 	<-make(chan struct{})
 }
+
+type testProxy struct {
+	addr    string
+	headers []string
+}
+
+func TestKeyBalancer(t *testing.T) {
+	balancer := structures.NewKeyBalancer(func(p testProxy) string { return p.addr },
+		structures.MaxErrsBalancerOpt(0),
+	)
+	balancer.Add(
+		testProxy{addr: "a", headers: []string{"x"}},
+		testProxy{addr: "b"},
+	)
+	res, ok := balancer.Use()
+	if !ok {
+		t.Errorf("expected true, got false")
+	}
+	if res.Data().addr != "a" {
+		t.Errorf("expected a, got %s", res.Data().addr)
+	}
+	res.Report()
+	if _, ok := balancer.Stats("a"); ok {
+		t.Errorf("expected a to be removed")
+	}
+	if balancer.Len() != 1 {
+		t.Errorf("expected 1, got %d", balancer.Len())
+	}
+	balancer.Remove("b")
+	if balancer.Len() != 0 {
+		t.Errorf("expected 0, got %d", balancer.Len())
+	}
+}
+
+func TestAcquireAdaptiveLimit(t *testing.T) {
+	balancer := structures.NewBalancer[int](
+		structures.AdaptiveLimitBalancerOpt(structures.AdaptiveLimit{Initial: 2, Min: 1, Max: 3}),
+	)
+	balancer.Add(1)
+	first, ok := balancer.Acquire()
+	if !ok {
+		t.Errorf("expected true, got false")
+	}
+	second, ok := balancer.Acquire()
+	if !ok {
+		t.Errorf("expected true, got false")
+	}
+	if _, ok := balancer.Acquire(); ok {
+		t.Errorf("expected value at limit to be skipped")
+	}
+
+	first.Timeout()
+	stats, _ := balancer.Stats(1)
+	if stats.Limit() != 1 {
+		t.Errorf("expected 1, got %d", stats.Limit())
+	}
+	if stats.InFlight() != 1 {
+		t.Errorf("expected 1, got %d", stats.InFlight())
+	}
+
+	// Only the first outcome of a response counts
+	second.Success()
+	second.Success()
+	second.Timeout()
+	stats, _ = balancer.Stats(1)
+	if stats.Limit() != 2 {
+		t.Errorf("expected 2, got %d", stats.Limit())
+	}
+	if stats.InFlight() != 0 {
+		t.Errorf("expected 0, got %d", stats.InFlight())
+	}
+}
+
+func TestBalancerOnReportRemoveReenters(t *testing.T) {
+	store, err := structures.NewFileBalancerStore(filepath.Join(t.TempDir(), "balancer.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []structures.BalancerOpt{
+		structures.StoreBalancerOpt(store),
+		structures.MaxErrsBalancerOpt(0),
+		structures.AdaptiveLimitBalancerOpt(structures.AdaptiveLimit{Initial: 1, Min: 1, Max: 1}),
+	}
+	b1, b2 := structures.NewBalancer[int](opts...), structures.NewBalancer[int](opts...)
+	b1.Add(1, 2)
+	b2.Add(1, 2)
+
+	// The callback runs while b2 acquires and must be able to call back in
+	var reacquired []int
+	b2.SetOnReportRemove(func(int) {
+		if res, ok := b2.Acquire(); ok {
+			reacquired = append(reacquired, res.Data())
+			res.Success()
+		}
+	})
+	res, _ := b1.Acquire()
+	res.Report()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, ok := b2.Acquire()
+		if !ok || res.Data() != 2 {
+			t.Errorf("expected 2, got %d", res.Data())
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire deadlocked in OnReportRemove")
+	}
+	if len(reacquired) != 1 || reacquired[0] != 2 {
+		t.Errorf("expected callback to acquire 2, got %v", reacquired)
+	}
+}
+
+func TestBalancerConcurrent(t *testing.T) {
+	balancer := structures.NewBalancer[int](
+		structures.MaxErrsBalancerOpt(2),
+		structures.AdaptiveLimitBalancerOpt(structures.AdaptiveLimit{Initial: 4, Min: 1, Max: 8}),
+	)
+	balancer.Add(1, 2, 3, 4)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				switch (g + i) % 5 {
+				case 0:
+					if res, ok := balancer.Use(); ok {
+						res.Use()
+						res.Report()
+					}
+				case 1:
+					if res, ok := balancer.Acquire(); ok {
+						res.Success()
+					}
+				case 2:
+					if res, ok := balancer.Acquire(); ok {
+						res.Timeout()
+					}
+				case 3:
+					balancer.Add(i % 6)
+				case 4:
+					balancer.Remove(i % 6)
+					balancer.Vals()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, val := range balancer.Vals() {
+		if stats, ok := balancer.Stats(val); !ok || stats.InFlight() != 0 {
+			t.Errorf("expected no slots held for %d", val)
+		}
+	}
+}
+
+func TestBalancerSharedStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "balancer.json")
+	newBalancer := func() *structures.Balancer[string] {
+		store, err := structures.NewFileBalancerStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		balancer := structures.NewBalancer[string](
+			structures.StoreBalancerOpt(store),
+			structures.MaxErrsBalancerOpt(0),
+			structures.QuarantineBalancerOpt(time.Minute),
+		)
+		balancer.Add("a", "b")
+		return balancer
+	}
+	b1, b2 := newBalancer(), newBalancer()
+
+	res, _ := b1.Use()
+	res.Use()
+	res.Report()
+
+	// b2 sees the quarantine set by b1 and skips "a"
+	res, ok := b2.Use()
+	if !ok {
+		t.Errorf("expected true, got false")
+	}
+	if res.Data() != "b" {
+		t.Errorf("expected b, got %s", res.Data())
+	}
+	stats, _ := b2.Stats("a")
+	if stats.QuarantinedUntil().IsZero() {
+		t.Errorf("expected a to be quarantined")
+	}
+	if stats.LastUsed().IsZero() {
+		t.Errorf("expected last used to be shared")
+	}
+}
+
+func TestBalancerSharedStoreRemove(t *testing.T) {
+	store, err := structures.NewFileBalancerStore(filepath.Join(t.TempDir(), "balancer.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1 := structures.NewBalancer[int](structures.StoreBalancerOpt(store), structures.MaxErrsBalancerOpt(0))
+	b2 := structures.NewBalancer[int](structures.StoreBalancerOpt(store), structures.MaxErrsBalancerOpt(0))
+	b1.Add(1, 2)
+	b2.Add(1, 2)
+
+	res, _ := b1.Use()
+	res.Report()
+	removed := 0
+	b2.SetOnReportRemove(func(int) { removed++ })
+	res, _ = b2.Use()
+	if res.Data() != 2 {
+		t.Errorf("expected 2, got %d", res.Data())
+	}
+	if b2.Len() != 1 || removed != 1 {
+		t.Errorf("expected 1 to be removed from b2")
+	}
+}
+
+func TestBalancerSharedStoreReAdd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "balancer.json")
+	newBalancer := func() *structures.Balancer[string] {
+		store, err := structures.NewFileBalancerStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return structures.NewBalancer[string](structures.StoreBalancerOpt(store), structures.MaxErrsBalancerOpt(0))
+	}
+	b1 := newBalancer()
+	b1.Add("a")
+	res, _ := b1.Use()
+	res.Report()
+	if b1.Len() != 0 {
+		t.Errorf("expected a to be removed")
+	}
+
+	// Adding it again clears the errors b1 stored itself
+	b1.Add("a")
+	res, ok := b1.Use()
+	if !ok || res.Data() != "a" {
+		t.Errorf("expected a to be usable after adding it again")
+	}
+	res.Report()
+
+	// A new balancer keeps the errors reported by b1 until it resets them
+	b2 := newBalancer()
+	b2.AddLast("a")
+	if _, ok := b2.Use(); ok || b2.Len() != 0 {
+		t.Errorf("expected a to be removed by a new balancer")
+	}
+	b2.Reset("a")
+	b2.Add("a")
+	if _, ok := b2.Use(); !ok || b2.Len() != 1 {
+		t.Errorf("expected a to be usable after Reset")
+	}
+}
+
+func TestFileBalancerStoreNull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "balancer.json")
+	if err := os.WriteFile(path, []byte("null"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := structures.NewFileBalancerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Update("a", func(state *structures.BalancerState) { state.Errors++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := store.Load("a"); state.Errors != 1 {
+		t.Errorf("expected 1, got %d", state.Errors)
+	}
+}
+
+func TestFileBalancerStoreAtomicWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "balancer.json")
+	s1, err := structures.NewFileBalancerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := structures.NewFileBalancerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, store := range []*structures.FileBalancerStore{s1, s2} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				err := store.Update(fmt.Sprint(i%5), func(state *structures.BalancerState) {
+					state.Errors++
+				})
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	// The file is only ever replaced, so readers always see valid JSON
+	for range 100 {
+		data, err := os.ReadFile(path)
+		if err == nil && len(data) > 0 && !json.Valid(data) {
+			t.Fatalf("read partial write %q", data)
+		}
+	}
+	wg.Wait()
+
+	// s1 picks up the updates made through s2
+	for i := range 5 {
+		state, err := s1.Load(fmt.Sprint(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Errors != 20 {
+			t.Errorf("expected 20, got %d", state.Errors)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected only the store and lock file, got %d files", len(entries))
+	}
+}
@@ -1,27 +1,49 @@
 package structures
 
 import (
+	"encoding/json"
+	"iter"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Cache[K comparable] struct {
-	expiry            time.Duration
-	items             map[K]time.Time
-	autoDeleteCancels map[K]func()
-	mu                sync.RWMutex
-	opts              *CacheOpts
+	expiry  time.Duration
+	items   map[K]time.Time
+	ttls    map[K]time.Duration
+	janitor *janitor[K]
+	onEvict func(K, EvictReason)
+	pending []evictEvent[K, struct{}]
+	mu      sync.RWMutex
+	opts    *CacheOpts
 }
 
 type CacheOpts struct {
-	AutoDelete bool
+	AutoDelete   bool
+	EvictOnRead  bool
+	MaxEntries   int
+	MaxCost      int64
+	Eviction     EvictionKind
+	NegativeTTL  *time.Duration
+	StaleTTL     *time.Duration
+	RefreshAhead *time.Duration
+	Sliding      bool
+	Shards       int
+	Clock        Clock
+	policyCap    int
 }
 
 type CacheOpt func(*CacheOpts)
 
 func NewCacheOptions(opts ...CacheOpt) *CacheOpts {
 	defaults := &CacheOpts{
-		AutoDelete: false,
+		AutoDelete:  false,
+		EvictOnRead: false,
+		MaxEntries:  -1,
+		MaxCost:     -1,
+		Eviction:    EvictionLRU,
+		Clock:       RealClock(),
 	}
 	for _, o := range opts {
 		o(defaults)
@@ -35,23 +57,131 @@ func AutoDeleteCacheOpt() CacheOpt {
 	}
 }
 
+// EvictOnReadCacheOpt makes single key reads like Contains, Has and Get
+// delete the expired entry they find instead of only hiding it.
+func EvictOnReadCacheOpt() CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.EvictOnRead = true
+	}
+}
+
+// MaxEntriesCacheOpt bounds a CacheMap to maxEntries entries. Adding a new
+// key to a full cache evicts an entry chosen by the eviction policy, the
+// least recently used one by default, where Add and Get count as uses.
+// Entries also still expire by TTL. A negative maxEntries means unbounded,
+// the default.
+func MaxEntriesCacheOpt(maxEntries int) CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.MaxEntries = maxEntries
+	}
+}
+
+// MaxCostCacheOpt bounds the total cost of the entries of a CacheMap to
+// maxCost. An entry's cost is given to AddWithCost or computed by the func
+// set with SetSizer, and is 1 otherwise. Adding entries beyond the budget
+// evicts entries chosen by the eviction policy until the total fits again.
+// An entry costing more than maxCost on its own is evicted right away
+// without making room for it first. It can be combined with
+// MaxEntriesCacheOpt. A negative maxCost means unbounded, the default.
+func MaxCostCacheOpt(maxCost int64) CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.MaxCost = maxCost
+	}
+}
+
+// EvictionCacheOpt picks the built-in eviction policy of a bounded CacheMap.
+// Use CacheMap.SetEvictionPolicy for a custom one.
+func EvictionCacheOpt(kind EvictionKind) CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.Eviction = kind
+	}
+}
+
+// NegativeTTLCacheOpt makes CacheMap.GetOrLoad remember a loader error for
+// ttl, returning it without calling the loader again until ttl passes.
+func NegativeTTLCacheOpt(ttl time.Duration) CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.NegativeTTL = &ttl
+	}
+}
+
+// StaleTTLCacheOpt keeps CacheMap entries for staleTTL after they expire.
+// Get and GetOrLoad return a stale entry right away and refresh it once in
+// the background through the loader, so callers don't wait on reloads.
+func StaleTTLCacheOpt(staleTTL time.Duration) CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.StaleTTL = &staleTTL
+	}
+}
+
+// RefreshAheadCacheOpt makes a Get within window of an entry's expiry reload
+// it in the background through the loader, so keys that keep being read
+// never expire.
+func RefreshAheadCacheOpt(window time.Duration) CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.RefreshAhead = &window
+	}
+}
+
+// SlidingExpirationCacheOpt makes every Cache.Contains and CacheMap.Get hit
+// reset the entry's TTL, so entries only expire once they stop being read.
+func SlidingExpirationCacheOpt() CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.Sliding = true
+	}
+}
+
+// ShardsCacheOpt sets the number of shards of a ShardedCacheMap, rounded up
+// to a power of two. The default of 0 picks 4 per CPU. Other caches ignore it.
+func ShardsCacheOpt(shards int) CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.Shards = shards
+	}
+}
+
+func ClockCacheOpt(clock Clock) CacheOpt {
+	return func(opts *CacheOpts) {
+		opts.Clock = clock
+	}
+}
+
 func NewCache[K comparable](expiry time.Duration, opts ...CacheOpt) *Cache[K] {
-	return &Cache[K]{
-		expiry:            expiry,
-		items:             map[K]time.Time{},
-		autoDeleteCancels: map[K]func(){},
-		mu:                sync.RWMutex{},
-		opts:              NewCacheOptions(opts...),
+	c := &Cache[K]{
+		expiry: expiry,
+		items:  map[K]time.Time{},
+		ttls:   map[K]time.Duration{},
+		mu:     sync.RWMutex{},
+		opts:   NewCacheOptions(opts...),
 	}
+	if c.opts.AutoDelete {
+		c.janitor = newJanitor[K](c.opts.Clock, c.sweep)
+	}
+	return c
 }
 
-func (c *Cache[K]) DeleteExpired() (deleted []K) {
-	now := time.Now()
+// SetOnEvict sets the func called for every key that leaves the cache, with
+// the reason it left. Renewing a live key with Add doesn't call it. fn runs
+// after the cache lock is released, so it may call back into the cache.
+func (c *Cache[K]) SetOnEvict(fn func(key K, reason EvictReason)) *Cache[K] {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.onEvict = fn
+	return c
+}
+
+func (c *Cache[K]) OnEvict() func(key K, reason EvictReason) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.onEvict
+}
+
+func (c *Cache[K]) DeleteExpired() (deleted []K) {
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.unlock()
 	for k, v := range c.items {
 		if now.Sub(v) >= 0 {
-			delete(c.items, k)
+			c.remove(k, EvictExpired)
 			deleted = append(deleted, k)
 		}
 	}
@@ -59,122 +189,427 @@ func (c *Cache[K]) DeleteExpired() (deleted []K) {
 }
 
 func (c *Cache[K]) Add(keys ...K) {
-	now := time.Now()
+	now := c.opts.Clock.Now()
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	for _, key := range keys {
-		c.items[key] = now.Add(c.expiry)
-		if c.opts.AutoDelete {
-			canceled := false
-			cancelFunc := func() {
-				canceled = true
-			}
-			if cancel, ok := c.autoDeleteCancels[key]; ok {
-				cancel()
-			}
-			c.autoDeleteCancels[key] = cancelFunc
-			go func(k K) {
-				time.Sleep(c.expiry)
-				c.mu.Lock()
-				defer c.mu.Unlock()
-				if !canceled {
-					delete(c.items, k)
-				}
-			}(key)
-		}
+		c.set(key, now, c.expiry)
 	}
 }
 
 func (c *Cache[K]) AddWithExpiry(key K, dur time.Duration) {
-	now := time.Now()
+	now := c.opts.Clock.Now()
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.items[key] = now.Add(dur)
-	if c.opts.AutoDelete {
-		canceled := false
-		cancelFunc := func() {
-			canceled = true
-		}
-		if cancel, ok := c.autoDeleteCancels[key]; ok {
-			cancel()
-		}
-		c.autoDeleteCancels[key] = cancelFunc
-		go func(k K) {
-			time.Sleep(dur)
-			c.mu.Lock()
-			defer c.mu.Unlock()
-			if !canceled {
-				delete(c.items, k)
-			}
-		}(key)
-	}
+	defer c.unlock()
+	c.set(key, now, dur)
 }
 
 func (c *Cache[K]) Delete(keys ...K) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	for _, key := range keys {
-		delete(c.items, key)
+		c.remove(key, EvictDeleted)
 	}
 }
 
+// Contains returns true if key is in the cache and hasn't expired.
 func (c *Cache[K]) Contains(key K) bool {
+	now := c.opts.Clock.Now()
 	c.mu.RLock()
-	defer c.mu.RUnlock()
-	_, ok := c.items[key]
+	expiry, ok := c.items[key]
+	c.mu.RUnlock()
+	if ok && expired(expiry, now) {
+		c.evictOnRead(key, now)
+		return false
+	}
+	if ok && c.opts.Sliding {
+		c.Touch(key)
+	}
 	return ok
 }
 
+// Len returns the number of keys that haven't expired.
 func (c *Cache[K]) Len() int {
+	now := c.opts.Clock.Now()
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return len(c.items)
+	n := 0
+	for _, expiry := range c.items {
+		if !expired(expiry, now) {
+			n++
+		}
+	}
+	return n
 }
 
+// Keys returns the keys that haven't expired.
 func (c *Cache[K]) Keys() []K {
+	now := c.opts.Clock.Now()
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	keys := make([]K, 0, len(c.items))
-	for k := range c.items {
-		keys = append(keys, k)
+	for k, expiry := range c.items {
+		if !expired(expiry, now) {
+			keys = append(keys, k)
+		}
 	}
 	return keys
 }
 
 func (c *Cache[K]) Clear() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
+	for k := range c.items {
+		c.record(k, EvictCleared)
+	}
 	c.items = make(map[K]time.Time)
+	clear(c.ttls)
+	c.janitor.clear()
+}
+
+// Close stops auto deletion. Expired keys stay until DeleteExpired is
+// called. Close is only needed with AutoDeleteCacheOpt and is safe to call
+// more than once.
+func (c *Cache[K]) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.janitor.close()
+}
+
+// evictOnRead deletes key if EvictOnRead is set and it is still expired.
+func (c *Cache[K]) evictOnRead(key K, now time.Time) {
+	if !c.opts.EvictOnRead {
+		return
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if expiry, ok := c.items[key]; ok && expired(expiry, now) {
+		c.remove(key, EvictExpired)
+	}
+}
+
+// sweep is run by the janitor when the earliest expiry is reached.
+func (c *Cache[K]) sweep() {
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.unlock()
+	for _, k := range c.janitor.due(now) {
+		delete(c.items, k)
+		delete(c.ttls, k)
+		c.record(k, EvictExpired)
+	}
+}
+
+// set stores key to expire after ttl. A key that had expired but wasn't
+// deleted yet is reported as expired. It must be called with mu held.
+func (c *Cache[K]) set(key K, now time.Time, ttl time.Duration) {
+	if old, ok := c.items[key]; ok && expired(old, now) {
+		c.record(key, EvictExpired)
+	}
+	// Only TTLs other than the default are kept, for Touch
+	if ttl == c.expiry {
+		delete(c.ttls, key)
+	} else {
+		c.ttls[key] = ttl
+	}
+	c.items[key] = now.Add(ttl)
+	c.janitor.schedule(key, now.Add(ttl))
+}
+
+// remove deletes key if present. It must be called with mu held.
+func (c *Cache[K]) remove(key K, reason EvictReason) {
+	if _, ok := c.items[key]; !ok {
+		return
+	}
+	delete(c.items, key)
+	delete(c.ttls, key)
+	c.janitor.unschedule(key)
+	c.record(key, reason)
+}
+
+// record queues an OnEvict call for when the lock is released.
+func (c *Cache[K]) record(key K, reason EvictReason) {
+	if c.onEvict != nil {
+		c.pending = append(c.pending, evictEvent[K, struct{}]{key: key, reason: reason})
+	}
+}
+
+// unlock releases mu and then runs the OnEvict calls queued while it was held.
+func (c *Cache[K]) unlock() {
+	pending, onEvict := c.pending, c.onEvict
+	c.pending = nil
+	c.mu.Unlock()
+	for _, e := range pending {
+		onEvict(e.key, e.reason)
+	}
+}
+
+// All returns an iterator over the keys of the cache and their expiry times.
+// Range over it with a single variable to iterate keys only. Keys are
+// snapshotted when the iteration starts and each expiry is read under a
+// short read lock, so the loop body may call other Cache methods. Keys
+// deleted during the iteration are skipped.
+func (c *Cache[K]) All() iter.Seq2[K, time.Time] {
+	return func(yield func(K, time.Time) bool) {
+		for _, k := range c.Keys() {
+			c.mu.RLock()
+			expiry, ok := c.items[k]
+			c.mu.RUnlock()
+			if !ok || expired(expiry, c.opts.Clock.Now()) {
+				continue
+			}
+			if !yield(k, expiry) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON encodes the cache as a JSON array of keys with their
+// remaining TTLs.
+func (c *Cache[K]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.entries())
+}
+
+// UnmarshalJSON replaces the cache contents. Each key expires after its
+// encoded remaining TTL.
+func (c *Cache[K]) UnmarshalJSON(data []byte) error {
+	var entries []cacheEntry[K]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	c.reset(entries)
+	return nil
+}
+
+// MarshalBinary encodes the cache with encoding/gob, keeping remaining TTLs.
+func (c *Cache[K]) MarshalBinary() ([]byte, error) {
+	return gobEncode(c.entries())
+}
+
+// UnmarshalBinary replaces the cache contents with gob encoded keys.
+func (c *Cache[K]) UnmarshalBinary(data []byte) error {
+	var entries []cacheEntry[K]
+	if err := gobDecode(data, &entries); err != nil {
+		return err
+	}
+	c.reset(entries)
+	return nil
+}
+
+func (c *Cache[K]) entries() []cacheEntry[K] {
+	c.init()
+	now := c.opts.Clock.Now()
+	entries := []cacheEntry[K]{}
+	for k, expiry := range c.All() {
+		if expiry.Equal(never) {
+			entries = append(entries, cacheEntry[K]{Key: k, TTL: NoTTL})
+		} else if ttl := expiry.Sub(now); ttl > 0 {
+			entries = append(entries, cacheEntry[K]{Key: k, TTL: ttl})
+		}
+	}
+	return entries
+}
+
+func (c *Cache[K]) reset(entries []cacheEntry[K]) {
+	c.init()
+	c.Clear()
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.unlock()
+	for _, e := range entries {
+		if e.TTL == NoTTL {
+			// Persisted directly, the default expiry may already have passed
+			c.set(e.Key, now, c.expiry)
+			c.items[e.Key] = never
+			c.janitor.unschedule(e.Key)
+		} else if e.TTL > 0 {
+			c.set(e.Key, now, e.TTL)
+		}
+	}
+}
+
+// init makes a zero Cache usable so it can be decoded into.
+func (c *Cache[K]) init() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts == nil {
+		c.opts = NewCacheOptions()
+	}
+	if c.items == nil {
+		c.items = map[K]time.Time{}
+	}
+	if c.ttls == nil {
+		c.ttls = map[K]time.Duration{}
+	}
+	if c.opts.AutoDelete && c.janitor == nil {
+		c.janitor = newJanitor[K](c.opts.Clock, c.sweep)
+	}
 }
 
 type CacheMap[K comparable, V any] struct {
-	expiry            time.Duration
-	items             map[K]V
-	itemExpiries      map[K]time.Time
-	autoDeleteCancels map[K]func()
-	mu                sync.RWMutex
-	opts              *CacheOpts
+	expiry       time.Duration
+	items        map[K]V
+	itemExpiries map[K]time.Time
+	ttls         map[K]time.Duration
+	costs        map[K]int64
+	used         map[K]uint64
+	janitor      *janitor[K]
+	policy       EvictionPolicy[K]
+	sizer        func(K, V) int64
+	shared       *shardBudget[K, V]
+	onEvict      func(K, V, EvictReason)
+	pending      []evictEvent[K, V]
+	loads        map[K]*cacheLoad[V]
+	loadErrs     map[K]cacheLoadErr
+	loader       CacheLoader[K, V]
+	hits         atomic.Int64
+	misses       atomic.Int64
+	evictions    atomic.Int64
+	cost         atomic.Int64
+	mu           sync.RWMutex
+	opts         *CacheOpts
+}
+
+// CacheStats counts how a CacheMap was used.
+type CacheStats struct {
+	hits      int
+	misses    int
+	evictions int
+	cost      int64
+	waiting   int
+}
+
+// Hits returns how many Get calls found a live entry.
+func (s CacheStats) Hits() int {
+	return s.hits
+}
+
+// Misses returns how many Get calls found nothing.
+func (s CacheStats) Misses() int {
+	return s.misses
+}
+
+// Evictions returns how many entries were evicted to stay within capacity.
+func (s CacheStats) Evictions() int {
+	return s.evictions
+}
+
+// Cost returns the total cost of the entries in the cache, see
+// MaxCostCacheOpt.
+func (s CacheStats) Cost() int64 {
+	return s.cost
+}
+
+// Waiting returns how many GetOrLoad callers and background refreshes are
+// waiting for a load to finish.
+func (s CacheStats) Waiting() int {
+	return s.waiting
+}
+
+// HitRatio returns hits / (hits + misses), or 0 before the first Get.
+func (s CacheStats) HitRatio() float64 {
+	if s.hits+s.misses == 0 {
+		return 0
+	}
+	return float64(s.hits) / float64(s.hits+s.misses)
 }
 
 func NewCacheMap[K comparable, V any](expiry time.Duration, opts ...CacheOpt) *CacheMap[K, V] {
-	return &CacheMap[K, V]{
-		expiry:            expiry,
-		items:             map[K]V{},
-		itemExpiries:      map[K]time.Time{},
-		autoDeleteCancels: map[K]func(){},
-		mu:                sync.RWMutex{},
-		opts:              NewCacheOptions(opts...),
+	c := &CacheMap[K, V]{
+		expiry:       expiry,
+		items:        map[K]V{},
+		itemExpiries: map[K]time.Time{},
+		ttls:         map[K]time.Duration{},
+		costs:        map[K]int64{},
+		loads:        map[K]*cacheLoad[V]{},
+		loadErrs:     map[K]cacheLoadErr{},
+		mu:           sync.RWMutex{},
+		opts:         NewCacheOptions(opts...),
 	}
+	if c.opts.AutoDelete {
+		c.janitor = newJanitor[K](c.opts.Clock, c.sweep)
+	}
+	if c.opts.bounded() {
+		c.policy = newEvictionPolicy[K](c.opts.Eviction, c.opts.policyCapacity())
+	}
+	return c
 }
 
-func (c *CacheMap[K, V]) DeleteExpired() (deleted []K) {
-	now := time.Now()
+// SetOnEvict sets the func called for every entry that leaves the cache,
+// with the reason it left. fn runs after the cache lock is released, so it
+// may call back into the cache.
+func (c *CacheMap[K, V]) SetOnEvict(fn func(key K, value V, reason EvictReason)) *CacheMap[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+	return c
+}
+
+func (c *CacheMap[K, V]) OnEvict() func(key K, value V, reason EvictReason) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.onEvict
+}
+
+// SetEvictionPolicy replaces the eviction policy of a cache bounded with
+// MaxEntriesCacheOpt or MaxCostCacheOpt. Entries already in the cache are
+// added to the new policy in no particular order. It has no effect on an
+// unbounded cache.
+func (c *CacheMap[K, V]) SetEvictionPolicy(policy EvictionPolicy[K]) *CacheMap[K, V] {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.policy == nil {
+		return c
+	}
+	c.policy = policy
+	for k := range c.items {
+		c.policy.Add(k)
+	}
+	return c
+}
+
+// SetSizer sets the func computing the cost of entries added without an
+// explicit cost, see MaxCostCacheOpt. fn runs with the cache locked and must
+// not call back into it. Entries already in the cache keep their cost.
+func (c *CacheMap[K, V]) SetSizer(fn func(key K, value V) int64) *CacheMap[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sizer = fn
+	return c
+}
+
+func (c *CacheMap[K, V]) Sizer() func(key K, value V) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sizer
+}
+
+// Stats returns the hit, miss and eviction counts and the total cost of the
+// cache.
+func (c *CacheMap[K, V]) Stats() CacheStats {
+	c.mu.RLock()
+	waiting := 0
+	for _, load := range c.loads {
+		waiting += load.waiters
+	}
+	c.mu.RUnlock()
+	return CacheStats{
+		hits:      int(c.hits.Load()),
+		misses:    int(c.misses.Load()),
+		evictions: int(c.evictions.Load()),
+		cost:      c.cost.Load(),
+		waiting:   waiting,
+	}
+}
+
+func (c *CacheMap[K, V]) DeleteExpired() (deleted []K) {
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.unlock()
 	for k, expiry := range c.itemExpiries {
 		if now.Sub(expiry) >= 0 {
-			delete(c.items, k)
-			delete(c.itemExpiries, k)
+			c.remove(k, EvictExpired)
 			deleted = append(deleted, k)
 		}
 	}
@@ -182,111 +617,574 @@ func (c *CacheMap[K, V]) DeleteExpired() (deleted []K) {
 }
 
 func (c *CacheMap[K, V]) Add(key K, value V) {
-	now := time.Now()
+	now := c.opts.Clock.Now()
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.itemExpiries[key] = now.Add(c.expiry)
-	c.items[key] = value
-	if c.opts.AutoDelete {
-		canceled := false
-		cancelFunc := func() {
-			canceled = true
-		}
-		if cancel, ok := c.autoDeleteCancels[key]; ok {
-			cancel()
-		}
-		c.autoDeleteCancels[key] = cancelFunc
-		go func(k K) {
-			time.Sleep(c.expiry)
-			c.mu.Lock()
-			defer c.mu.Unlock()
-			if !canceled {
-				delete(c.items, k)
-				delete(c.itemExpiries, k)
-			}
-		}(key)
-	}
+	defer c.unlock()
+	c.set(key, value, now, c.expiry, c.costOf(key, value))
 }
 
 func (c *CacheMap[K, V]) AddWithExpiry(key K, value V, dur time.Duration) {
-	now := time.Now()
+	now := c.opts.Clock.Now()
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.itemExpiries[key] = now.Add(dur)
-	c.items[key] = value
-	if c.opts.AutoDelete {
-		canceled := false
-		cancelFunc := func() {
-			canceled = true
-		}
-		if cancel, ok := c.autoDeleteCancels[key]; ok {
-			cancel()
-		}
-		c.autoDeleteCancels[key] = cancelFunc
-		go func(k K) {
-			time.Sleep(dur)
-			c.mu.Lock()
-			defer c.mu.Unlock()
-			if !canceled {
-				delete(c.items, k)
-				delete(c.itemExpiries, k)
-			}
-		}(key)
-	}
+	defer c.unlock()
+	c.set(key, value, now, dur, c.costOf(key, value))
+}
+
+// AddWithCost adds an entry that counts cost against MaxCostCacheOpt
+// instead of the cost computed by the sizer.
+func (c *CacheMap[K, V]) AddWithCost(key K, value V, cost int64) {
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.unlock()
+	c.set(key, value, now, c.expiry, cost)
 }
 
 func (c *CacheMap[K, V]) Delete(keys ...K) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	for _, key := range keys {
-		delete(c.items, key)
-		delete(c.itemExpiries, key)
+		c.remove(key, EvictDeleted)
 	}
 }
 
+// Has returns true if key is in the cache and hasn't expired. Unlike Get it
+// doesn't count as a use for MaxEntriesCacheOpt, and it ignores stale
+// entries kept by StaleTTLCacheOpt.
 func (c *CacheMap[K, V]) Has(key K) bool {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	_, ok := c.items[key]
-	return ok
+	_, expiry, ok := c.lookup(key)
+	return ok && c.fresh(expiry, c.opts.Clock.Now())
 }
 
+// Len returns the number of entries that haven't expired. Stale entries
+// kept by StaleTTLCacheOpt don't count.
 func (c *CacheMap[K, V]) Len() int {
+	now := c.opts.Clock.Now()
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return len(c.items)
+	n := 0
+	for _, expiry := range c.itemExpiries {
+		if c.fresh(expiry, now) {
+			n++
+		}
+	}
+	return n
 }
 
+// Keys returns the keys of the entries that haven't expired, without stale
+// ones.
 func (c *CacheMap[K, V]) Keys() []K {
+	now := c.opts.Clock.Now()
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	keys := make([]K, 0, len(c.items))
-	for k := range c.items {
-		keys = append(keys, k)
+	for k, expiry := range c.itemExpiries {
+		if c.fresh(expiry, now) {
+			keys = append(keys, k)
+		}
 	}
 	return keys
 }
 
+// Vals returns the values of the entries that haven't expired, without stale
+// ones.
 func (c *CacheMap[K, V]) Vals() []V {
+	now := c.opts.Clock.Now()
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	vals := make([]V, 0, len(c.items))
-	for _, v := range c.items {
-		vals = append(vals, v)
+	for k, v := range c.items {
+		if c.fresh(c.itemExpiries[k], now) {
+			vals = append(vals, v)
+		}
 	}
 	return vals
 }
 
+// Get returns the value for key. It returns false if key isn't in the cache
+// or has expired. With StaleTTLCacheOpt or RefreshAheadCacheOpt it may start
+// a background refresh through the loader set with SetLoader.
 func (c *CacheMap[K, V]) Get(key K) (value V, ok bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	value, ok = c.items[key]
+	return c.get(key, c.Loader())
+}
+
+// get is Get refreshing through loader, which may be nil.
+func (c *CacheMap[K, V]) get(key K, loader CacheLoader[K, V]) (value V, ok bool) {
+	value, expiry, ok := c.lookup(key)
+	if !ok {
+		c.misses.Add(1)
+		return value, false
+	}
+	c.hits.Add(1)
+	if c.policy != nil {
+		c.mu.Lock()
+		if _, stored := c.items[key]; stored {
+			c.policy.Access(key)
+			c.markUsed(key)
+		}
+		c.mu.Unlock()
+	}
+	if c.opts.Sliding {
+		c.Touch(key)
+	}
+	if loader != nil && c.needsRefresh(expiry) {
+		c.refresh(key, loader)
+	}
 	return value, ok
 }
 
 func (c *CacheMap[K, V]) Clear() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
+	for k, v := range c.items {
+		c.record(k, v, EvictCleared)
+	}
+	c.shared.track(-len(c.items), -c.cost.Load())
 	c.items = map[K]V{}
 	c.itemExpiries = map[K]time.Time{}
+	clear(c.ttls)
+	clear(c.costs)
+	clear(c.used)
+	c.cost.Store(0)
+	clear(c.loadErrs)
+	c.janitor.clear()
+	if c.policy != nil {
+		c.policy.Clear()
+	}
+}
+
+// Close stops auto deletion. Expired entries stay until DeleteExpired is
+// called. Close is only needed with AutoDeleteCacheOpt and is safe to call
+// more than once.
+func (c *CacheMap[K, V]) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.janitor.close()
+}
+
+// evictOnRead deletes key if EvictOnRead is set and it is still expired.
+func (c *CacheMap[K, V]) evictOnRead(key K, now time.Time) {
+	if !c.opts.EvictOnRead {
+		return
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if expiry, ok := c.itemExpiries[key]; ok && expired(expiry, now) {
+		c.remove(key, EvictExpired)
+	}
+}
+
+// sweep is run by the janitor when the earliest expiry is reached.
+func (c *CacheMap[K, V]) sweep() {
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.unlock()
+	for _, k := range c.janitor.due(now) {
+		c.record(k, c.items[k], EvictExpired)
+		delete(c.items, k)
+		delete(c.itemExpiries, k)
+		delete(c.ttls, k)
+		c.dropCost(k)
+		if c.policy != nil {
+			c.policy.Remove(k)
+		}
+	}
+}
+
+// lookup returns the value for key and the time it is deleted, unless it is
+// missing or expired, without counting as a use.
+func (c *CacheMap[K, V]) lookup(key K) (value V, expiry time.Time, ok bool) {
+	now := c.opts.Clock.Now()
+	c.mu.RLock()
+	value, ok = c.items[key]
+	expiry = c.itemExpiries[key]
+	c.mu.RUnlock()
+	if ok && expired(expiry, now) {
+		c.evictOnRead(key, now)
+		var zero V
+		return zero, expiry, false
+	}
+	return value, expiry, ok
+}
+
+// staleTTL returns how long entries are kept after they expire.
+func (c *CacheMap[K, V]) staleTTL() time.Duration {
+	if c.opts.StaleTTL == nil {
+		return 0
+	}
+	return *c.opts.StaleTTL
+}
+
+// fresh reports whether an entry deleted at expiry hasn't expired yet, not
+// counting the stale window.
+func (c *CacheMap[K, V]) fresh(expiry, now time.Time) bool {
+	return !expired(expiry.Add(-c.staleTTL()), now)
+}
+
+// needsRefresh reports whether an entry deleted at expiry is stale or, with
+// RefreshAhead, about to expire.
+func (c *CacheMap[K, V]) needsRefresh(expiry time.Time) bool {
+	fresh := expiry.Add(-c.staleTTL())
+	if c.opts.RefreshAhead != nil {
+		fresh = fresh.Add(-*c.opts.RefreshAhead)
+	}
+	return !c.opts.Clock.Now().Before(fresh)
+}
+
+// set stores an entry that expires after ttl, and is deleted StaleTTL after
+// that. If the cache is bounded it evicts entries chosen by the policy until
+// it fits. It must be called with mu held.
+func (c *CacheMap[K, V]) set(key K, value V, now time.Time, ttl time.Duration, cost int64) {
+	old, exists := c.items[key]
+	reason := EvictReplaced
+	if exists && expired(c.itemExpiries[key], now) {
+		reason = EvictExpired
+	}
+	if c.opts.MaxCost >= 0 && cost > c.opts.MaxCost {
+		// It would never fit, so don't evict everything else first
+		c.remove(key, reason)
+		c.record(key, value, EvictEvicted)
+		c.evictions.Add(1)
+		return
+	}
+	expiry := now.Add(ttl + c.staleTTL())
+	// Only TTLs other than the default are kept, for Touch
+	if ttl == c.expiry {
+		delete(c.ttls, key)
+	} else {
+		c.ttls[key] = ttl
+	}
+	if exists {
+		c.record(key, old, reason)
+	}
+	delete(c.loadErrs, key)
+	c.items[key] = value
+	c.itemExpiries[key] = expiry
+	c.setCost(key, cost)
+	c.janitor.schedule(key, expiry)
+	if c.policy == nil {
+		return
+	}
+	if exists {
+		// A replaced entry may cost more than before
+		c.policy.Access(key)
+	} else {
+		c.policy.Add(key)
+	}
+	c.markUsed(key)
+	c.evictOverCapacity()
+}
+
+// evictOverCapacity evicts entries chosen by the policy until the cache fits
+// in MaxEntries and MaxCost. It must be called with mu held.
+func (c *CacheMap[K, V]) evictOverCapacity() {
+	for c.overCapacity() {
+		victim, ok := c.policy.Evict()
+		if !ok {
+			return
+		}
+		c.evict(victim)
+	}
+}
+
+// evictOne evicts the entry chosen by the policy and reports whether there
+// was one. Unlike other writes it doesn't rebalance a ShardedCacheMap.
+func (c *CacheMap[K, V]) evictOne() bool {
+	c.mu.Lock()
+	defer c.release()
+	if c.policy == nil {
+		return false
+	}
+	victim, ok := c.policy.Evict()
+	if ok {
+		c.evict(victim)
+	}
+	return ok
+}
+
+// markUsed stamps key with the use clock shared by the shards of a
+// ShardedCacheMap, so their eviction candidates can be compared. It must be
+// called with mu held.
+func (c *CacheMap[K, V]) markUsed(key K) {
+	if c.shared == nil {
+		return
+	}
+	if c.used == nil {
+		c.used = map[K]uint64{}
+	}
+	c.used[key] = c.shared.clock.Add(1)
+}
+
+// candidate returns the use stamp of the entry the policy would evict next.
+// It returns false if the cache is empty or the policy can't peek.
+func (c *CacheMap[K, V]) candidate() (used uint64, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	peeker, ok := c.policy.(EvictionPeeker[K])
+	if !ok {
+		return 0, false
+	}
+	key, ok := peeker.Peek()
+	if !ok {
+		return 0, false
+	}
+	return c.used[key], true
+}
+
+// evict deletes a victim the policy already let go of. It must be called
+// with mu held.
+func (c *CacheMap[K, V]) evict(victim K) {
+	c.record(victim, c.items[victim], EvictEvicted)
+	delete(c.items, victim)
+	delete(c.itemExpiries, victim)
+	delete(c.ttls, victim)
+	c.dropCost(victim)
+	c.janitor.unschedule(victim)
+	c.evictions.Add(1)
+}
+
+// overCapacity reports whether the cache holds more entries or cost than it
+// is bounded to. It must be called with mu held.
+func (c *CacheMap[K, V]) overCapacity() bool {
+	return (c.opts.MaxEntries >= 0 && len(c.items) > c.opts.MaxEntries) ||
+		(c.opts.MaxCost >= 0 && c.cost.Load() > c.opts.MaxCost)
+}
+
+// costOf returns the cost of an entry added without an explicit cost. It
+// must be called with mu held.
+func (c *CacheMap[K, V]) costOf(key K, value V) int64 {
+	if c.sizer == nil {
+		return 1
+	}
+	return c.sizer(key, value)
+}
+
+// setCost records the cost of key, replacing its old cost, and counts it
+// against the budget shared by the shards of a ShardedCacheMap. It must be called with mu held.
+func (c *CacheMap[K, V]) setCost(key K, cost int64) {
+	old, ok := c.costs[key]
+	if ok {
+		c.shared.track(0, cost-old)
+	} else {
+		c.shared.track(1, cost)
+	}
+	c.cost.Add(cost - old)
+	c.costs[key] = cost
+}
+
+// dropCost forgets the cost and use stamp of key. It must be called with mu
+// held.
+func (c *CacheMap[K, V]) dropCost(key K) {
+	delete(c.used, key)
+	old, ok := c.costs[key]
+	if !ok {
+		return
+	}
+	c.shared.track(-1, -old)
+	c.cost.Add(-old)
+	delete(c.costs, key)
+}
+
+// remove deletes key everywhere it is tracked. It must be called with mu held.
+func (c *CacheMap[K, V]) remove(key K, reason EvictReason) {
+	delete(c.loadErrs, key)
+	value, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.record(key, value, reason)
+	delete(c.items, key)
+	delete(c.itemExpiries, key)
+	delete(c.ttls, key)
+	c.dropCost(key)
+	c.janitor.unschedule(key)
+	if c.policy != nil {
+		c.policy.Remove(key)
+	}
+}
+
+// record queues an OnEvict call for when the lock is released.
+func (c *CacheMap[K, V]) record(key K, value V, reason EvictReason) {
+	if c.onEvict != nil {
+		c.pending = append(c.pending, evictEvent[K, V]{key: key, value: value, reason: reason})
+	}
+}
+
+// unlock releases mu, runs the OnEvict calls queued while it was held and,
+// in a ShardedCacheMap, evicts from the shards until they fit together.
+func (c *CacheMap[K, V]) unlock() {
+	c.release()
+	c.shared.enforce()
+}
+
+// release releases mu and then runs the OnEvict calls queued while it was held.
+func (c *CacheMap[K, V]) release() {
+	pending, onEvict := c.pending, c.onEvict
+	c.pending = nil
+	c.mu.Unlock()
+	for _, e := range pending {
+		onEvict(e.key, e.value, e.reason)
+	}
+}
+
+// All returns an iterator over the key-value pairs of the cache. Range over
+// it with a single variable to iterate keys only. Keys are snapshotted when
+// the iteration starts and each value is read under a short read lock, so
+// the loop body may call other CacheMap methods. Keys deleted during the
+// iteration are skipped.
+func (c *CacheMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, k := range c.Keys() {
+			v, expiry, ok := c.lookup(k)
+			if !ok || !c.fresh(expiry, c.opts.Clock.Now()) {
+				continue
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the values of the cache with the same
+// snapshot semantics as All.
+func (c *CacheMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range c.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON encodes the cache as a JSON array of entries with their
+// remaining TTLs.
+func (c *CacheMap[K, V]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.entries())
+}
+
+// UnmarshalJSON replaces the cache contents. Each entry expires after its
+// encoded remaining TTL.
+func (c *CacheMap[K, V]) UnmarshalJSON(data []byte) error {
+	var entries []cacheMapEntry[K, V]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	c.reset(entries)
+	return nil
+}
+
+// MarshalBinary encodes the cache with encoding/gob, keeping remaining TTLs.
+func (c *CacheMap[K, V]) MarshalBinary() ([]byte, error) {
+	return gobEncode(c.entries())
+}
+
+// UnmarshalBinary replaces the cache contents with gob encoded entries.
+func (c *CacheMap[K, V]) UnmarshalBinary(data []byte) error {
+	var entries []cacheMapEntry[K, V]
+	if err := gobDecode(data, &entries); err != nil {
+		return err
+	}
+	c.reset(entries)
+	return nil
+}
+
+func (c *CacheMap[K, V]) entries() []cacheMapEntry[K, V] {
+	c.init()
+	now := c.opts.Clock.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entries := make([]cacheMapEntry[K, V], 0, len(c.items))
+	for k, v := range c.items {
+		if c.itemExpiries[k].Equal(never) {
+			entries = append(entries, cacheMapEntry[K, V]{Key: k, Value: v, TTL: NoTTL})
+		} else if ttl := c.itemExpiries[k].Sub(now) - c.staleTTL(); ttl > 0 {
+			entries = append(entries, cacheMapEntry[K, V]{Key: k, Value: v, TTL: ttl})
+		}
+	}
+	return entries
+}
+
+func (c *CacheMap[K, V]) reset(entries []cacheMapEntry[K, V]) {
+	c.init()
+	c.Clear()
+	c.restore(entries)
+}
+
+// restore adds decoded entries, keeping their remaining TTLs.
+func (c *CacheMap[K, V]) restore(entries []cacheMapEntry[K, V]) {
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.unlock()
+	for _, e := range entries {
+		if e.TTL == NoTTL {
+			// Persisted directly, the default expiry may already have passed
+			c.set(e.Key, e.Value, now, c.expiry, c.costOf(e.Key, e.Value))
+			if _, ok := c.items[e.Key]; ok {
+				c.itemExpiries[e.Key] = never
+				c.janitor.unschedule(e.Key)
+			}
+		} else if e.TTL > 0 {
+			c.set(e.Key, e.Value, now, e.TTL, c.costOf(e.Key, e.Value))
+		}
+	}
+}
+
+// init makes a zero CacheMap usable so it can be decoded into.
+func (c *CacheMap[K, V]) init() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts == nil {
+		c.opts = NewCacheOptions()
+	}
+	if c.items == nil {
+		c.items = map[K]V{}
+	}
+	if c.itemExpiries == nil {
+		c.itemExpiries = map[K]time.Time{}
+	}
+	if c.ttls == nil {
+		c.ttls = map[K]time.Duration{}
+	}
+	if c.costs == nil {
+		c.costs = map[K]int64{}
+	}
+	if c.loads == nil {
+		c.loads = map[K]*cacheLoad[V]{}
+	}
+	if c.loadErrs == nil {
+		c.loadErrs = map[K]cacheLoadErr{}
+	}
+	if c.opts.AutoDelete && c.janitor == nil {
+		c.janitor = newJanitor[K](c.opts.Clock, c.sweep)
+	}
+	if c.opts.bounded() && c.policy == nil {
+		c.policy = newEvictionPolicy[K](c.opts.Eviction, c.opts.policyCapacity())
+	}
+}
+
+// bounded reports whether a CacheMap with these options needs an eviction
+// policy.
+func (o *CacheOpts) bounded() bool {
+	return o.MaxEntries >= 0 || o.MaxCost >= 0
+}
+
+// policyCapacity returns how many entries the eviction policy is sized for.
+// The shards of a ShardedCacheMap are sized for their share. A cache only bounded by cost is sized for at most defaultPolicyCapacity
+// entries, which only affects how ARC and TinyLFU split their segments.
+func (o *CacheOpts) policyCapacity() int {
+	if o.policyCap > 0 {
+		return o.policyCap
+	}
+	if o.MaxEntries >= 0 {
+		return o.MaxEntries
+	}
+	return int(min(o.MaxCost, defaultPolicyCapacity))
+}
+
+const defaultPolicyCapacity = 10_000
+
+// expired reports whether an entry expiring at expiry is gone at now.
+func expired(expiry, now time.Time) bool {
+	return !now.Before(expiry)
 }
@@ -0,0 +1,145 @@
+package structures
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoCacheLoader is returned by GetOrLoad when it has no loader to call.
+var ErrNoCacheLoader = errors.New("no cache loader")
+
+// CacheLoader loads the value for key on a cache miss. A ttl > 0 overrides
+// the cache's default expiry for the loaded entry.
+type CacheLoader[K comparable, V any] func(ctx context.Context, key K) (value V, ttl time.Duration, err error)
+
+// cacheLoad is a load in flight shared by every GetOrLoad caller waiting on
+// the same key.
+type cacheLoad[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// cacheLoadErr is a loader error remembered for NegativeTTLCacheOpt.
+type cacheLoadErr struct {
+	err    error
+	expiry time.Time
+}
+
+// SetLoader sets the loader used by Get for StaleTTLCacheOpt and
+// RefreshAheadCacheOpt refreshes, and by GetOrLoad when it is passed a nil
+// loader.
+func (c *CacheMap[K, V]) SetLoader(loader CacheLoader[K, V]) *CacheMap[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loader = loader
+	return c
+}
+
+func (c *CacheMap[K, V]) Loader() CacheLoader[K, V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loader
+}
+
+// GetOrLoad returns the value for key, calling loader on a miss and storing
+// its result. A nil loader means the one set with SetLoader. Concurrent
+// calls for the same key share a single loader call. The load isn't tied to
+// any one caller: a caller whose ctx is done returns ctx.Err() right away,
+// and the load is only canceled once every caller waiting on it has given
+// up. Loader errors aren't stored unless NegativeTTLCacheOpt is set.
+func (c *CacheMap[K, V]) GetOrLoad(ctx context.Context, key K, loader CacheLoader[K, V]) (value V, err error) {
+	if loader == nil {
+		loader = c.Loader()
+	}
+	if value, ok := c.get(key, loader); ok {
+		return value, nil
+	}
+	if loader == nil {
+		return value, ErrNoCacheLoader
+	}
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	if value, ok := c.items[key]; ok && !expired(c.itemExpiries[key], now) {
+		c.mu.Unlock()
+		return value, nil
+	}
+	if loadErr, ok := c.loadErrs[key]; ok {
+		if !expired(loadErr.expiry, now) {
+			c.mu.Unlock()
+			return value, loadErr.err
+		}
+		delete(c.loadErrs, key)
+	}
+	load, ok := c.loads[key]
+	if !ok {
+		load = c.startLoad(context.WithoutCancel(ctx), key, loader)
+	}
+	load.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-load.done:
+		return load.val, load.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		load.waiters--
+		if load.waiters == 0 {
+			// Later callers start a fresh load instead of joining a canceled one
+			load.cancel()
+			if c.loads[key] == load {
+				delete(c.loads, key)
+			}
+		}
+		c.mu.Unlock()
+		return value, ctx.Err()
+	}
+}
+
+// refresh reloads key in the background unless a load is already running.
+// The refresh counts as a waiter of its own, so callers giving up can't
+// cancel it.
+func (c *CacheMap[K, V]) refresh(key K, loader CacheLoader[K, V]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.loads[key]; ok {
+		return
+	}
+	c.startLoad(context.Background(), key, loader).waiters++
+}
+
+// startLoad runs loader for key in a new goroutine. It must be called with mu held.
+func (c *CacheMap[K, V]) startLoad(ctx context.Context, key K, loader CacheLoader[K, V]) *cacheLoad[V] {
+	ctx, cancel := context.WithCancel(ctx)
+	load := &cacheLoad[V]{done: make(chan struct{}), cancel: cancel}
+	c.loads[key] = load
+	go c.load(ctx, key, load, loader)
+	return load
+}
+
+// load runs loader and stores its result for the waiters and the cache. A
+// failed refresh leaves the stale entry in place.
+func (c *CacheMap[K, V]) load(ctx context.Context, key K, load *cacheLoad[V], loader CacheLoader[K, V]) {
+	defer load.cancel()
+	value, ttl, err := loader(ctx, key)
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.unlock()
+	if c.loads[key] == load {
+		delete(c.loads, key)
+	}
+	load.val, load.err = value, err
+	close(load.done)
+	switch {
+	case err == nil:
+		if ttl <= 0 {
+			ttl = c.expiry
+		}
+		c.set(key, value, now, ttl, c.costOf(key, value))
+	case c.opts.NegativeTTL != nil && ctx.Err() == nil:
+		c.loadErrs[key] = cacheLoadErr{err: err, expiry: now.Add(*c.opts.NegativeTTL)}
+	}
+}
@@ -0,0 +1,206 @@
+package structures_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestCacheMapGetOrLoad(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewCacheMap[string, int](time.Minute)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (int, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return len(key), 0, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(context.Background(), "abc", loader)
+			is.NoErr(err)
+			is.Equal(v, 3)
+		}()
+	}
+	// Every caller joins the load before it finishes
+	waitForWaiters(cache, 100)
+	close(release)
+	wg.Wait()
+	is.Equal(calls.Load(), int32(1))
+
+	v, ok := cache.Get("abc")
+	is.True(ok)
+	is.Equal(v, 3)
+}
+
+func TestCacheMapGetOrLoadTTL(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[string, int](time.Minute, structures.ClockCacheOpt(clock))
+	_, err := cache.GetOrLoad(context.Background(), "a", func(ctx context.Context, key string) (int, time.Duration, error) {
+		return 1, time.Second, nil
+	})
+	is.NoErr(err)
+	clock.Advance(time.Second)
+	is.True(!cache.Has("a"))
+}
+
+func TestCacheMapGetOrLoadNegativeTTL(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[string, int](time.Minute,
+		structures.NegativeTTLCacheOpt(time.Second),
+		structures.ClockCacheOpt(clock),
+	)
+	errUpstream := errors.New("upstream down")
+	calls := 0
+	loader := func(ctx context.Context, key string) (int, time.Duration, error) {
+		calls++
+		if calls == 1 {
+			return 0, 0, errUpstream
+		}
+		return 1, 0, nil
+	}
+
+	_, err := cache.GetOrLoad(context.Background(), "a", loader)
+	is.Equal(err, errUpstream)
+	_, err = cache.GetOrLoad(context.Background(), "a", loader)
+	is.Equal(err, errUpstream) // remembered, loader not called
+	is.Equal(calls, 1)
+
+	clock.Advance(time.Second)
+	v, err := cache.GetOrLoad(context.Background(), "a", loader)
+	is.NoErr(err)
+	is.Equal(v, 1)
+	is.Equal(calls, 2)
+}
+
+func TestCacheMapGetOrLoadCancel(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewCacheMap[string, int](time.Minute)
+	loadCtx := make(chan context.Context, 1)
+	loader := func(ctx context.Context, key string) (int, time.Duration, error) {
+		loadCtx <- ctx
+		<-ctx.Done()
+		return 0, 0, ctx.Err()
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() { _, err := cache.GetOrLoad(ctx1, "a", loader); errs <- err }()
+	go func() { _, err := cache.GetOrLoad(ctx2, "a", loader); errs <- err }()
+	waitForWaiters(cache, 2)
+	ctx := <-loadCtx
+
+	cancel1()
+	is.Equal(<-errs, context.Canceled)
+	is.NoErr(ctx.Err()) // a caller still waits
+
+	cancel2()
+	is.Equal(<-errs, context.Canceled)
+	is.Equal(ctx.Err(), context.Canceled)
+
+	v, err := cache.GetOrLoad(context.Background(), "a", func(ctx context.Context, key string) (int, time.Duration, error) {
+		return 1, 0, nil
+	})
+	is.NoErr(err)
+	is.Equal(v, 1)
+}
+
+func TestCacheMapStaleWhileRevalidate(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[string, int](time.Second,
+		structures.StaleTTLCacheOpt(time.Minute),
+		structures.ClockCacheOpt(clock),
+	)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	cache.SetLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return 2, 0, nil
+	})
+	cache.Add("a", 1)
+
+	clock.Advance(time.Second)
+	for range 10 {
+		v, ok := cache.Get("a") // stale, served right away
+		is.True(ok)
+		is.Equal(v, 1)
+	}
+	is.Equal(cache.Stats().Waiting(), 1) // one refresh
+	// Stale entries are only served by Get
+	is.True(!cache.Has("a"))
+	is.Equal(cache.Len(), 0)
+	is.Equal(len(cache.Keys()), 0)
+	close(release)
+	waitForWaiters(cache, 0)
+	v, _ := cache.Get("a")
+	is.Equal(v, 2)
+	is.Equal(calls.Load(), int32(1))
+
+	clock.Advance(time.Second + time.Minute)
+	_, ok := cache.Get("a")
+	is.True(!ok) // past the stale window
+}
+
+func TestCacheMapRefreshAhead(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[string, int](time.Minute,
+		structures.RefreshAheadCacheOpt(10*time.Second),
+		structures.ClockCacheOpt(clock),
+	)
+	// Each load waits for a token, so a started refresh stays visible
+	tokens := make(chan struct{}, 1)
+	loads := 0
+	cache.SetLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+		<-tokens
+		loads++
+		return loads, 0, nil
+	})
+	tokens <- struct{}{}
+	v, err := cache.GetOrLoad(context.Background(), "a", nil)
+	is.NoErr(err)
+	is.Equal(v, 1)
+
+	clock.Advance(40 * time.Second)
+	cache.Get("a")
+	is.Equal(cache.Stats().Waiting(), 0) // not refreshed yet
+
+	clock.Advance(15 * time.Second)
+	v, _ = cache.Get("a")
+	is.Equal(v, 1) // served while refreshing
+	is.Equal(cache.Stats().Waiting(), 1)
+	tokens <- struct{}{}
+	waitForWaiters(cache, 0)
+	v, _ = cache.Get("a")
+	is.Equal(v, 2)
+}
+
+// waitForWaiters blocks until n callers wait for loads of cache.
+func waitForWaiters(cache *structures.CacheMap[string, int], n int) {
+	for cache.Stats().Waiting() != n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCacheMapGetOrLoadNoLoader(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewCacheMap[string, int](time.Minute)
+	_, err := cache.GetOrLoad(context.Background(), "a", nil)
+	is.Equal(err, structures.ErrNoCacheLoader)
+}
@@ -0,0 +1,414 @@
+package structures
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedCacheMap is a CacheMap split into shards, each with its own lock
+// and, with AutoDeleteCacheOpt, its own janitor, so goroutines working on
+// different keys rarely contend. Keys are assigned to shards by a hash
+// function, maphash.Comparable by default.
+//
+// MaxEntriesCacheOpt and MaxCostCacheOpt bound all shards together. Once
+// the cache is full, the policies of up to 8 shards each pick a candidate
+// and the one used least recently is evicted, so the eviction order is only
+// approximately the policy's across shards. With concurrent writers a few
+// more entries than needed may be evicted.
+type ShardedCacheMap[K comparable, V any] struct {
+	shards []*CacheMap[K, V]
+	hash   func(K) uint64
+}
+
+// NewShardedCacheMap returns a sharded cache where entries expire after
+// expiry. opts apply to every shard; use ShardsCacheOpt to set the number of
+// shards.
+func NewShardedCacheMap[K comparable, V any](expiry time.Duration, opts ...CacheOpt) *ShardedCacheMap[K, V] {
+	o := NewCacheOptions(opts...)
+	s := &ShardedCacheMap[K, V]{
+		shards: make([]*CacheMap[K, V], shardCount(o.Shards)),
+		hash:   defaultHasher[K](),
+	}
+	var budget *shardBudget[K, V]
+	if o.bounded() {
+		// Each shard keeps the global bounds, which it can only exceed if
+		// the whole cache does, but sizes its policy for its share
+		perShard := (o.policyCapacity() + len(s.shards) - 1) / len(s.shards)
+		opts = append(opts[:len(opts):len(opts)], func(opts *CacheOpts) {
+			opts.policyCap = perShard
+		})
+		budget = &shardBudget[K, V]{shards: s.shards, maxEntries: o.MaxEntries, maxCost: o.MaxCost}
+	}
+	for i := range s.shards {
+		s.shards[i] = NewCacheMap[K, V](expiry, opts...)
+		s.shards[i].shared = budget
+	}
+	return s
+}
+
+// SetHasher replaces the function that assigns keys to shards. Entries
+// already in the cache are moved to their new shards with their expiries,
+// so it is cheapest to call before the cache is used. It must not run
+// concurrently with other methods.
+func (s *ShardedCacheMap[K, V]) SetHasher(hash func(K) uint64) *ShardedCacheMap[K, V] {
+	s.hash = hash
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+	}
+	for _, shard := range s.shards {
+		for k := range shard.items {
+			if dst := s.shard(k); dst != shard {
+				shard.moveTo(k, dst)
+			}
+		}
+	}
+	for _, shard := range s.shards {
+		shard.release()
+	}
+	return s
+}
+
+func (s *ShardedCacheMap[K, V]) Shards() int {
+	return len(s.shards)
+}
+
+// SetOnEvict sets the func called for every entry that leaves any shard.
+func (s *ShardedCacheMap[K, V]) SetOnEvict(fn func(key K, value V, reason EvictReason)) *ShardedCacheMap[K, V] {
+	for _, shard := range s.shards {
+		shard.SetOnEvict(fn)
+	}
+	return s
+}
+
+func (s *ShardedCacheMap[K, V]) OnEvict() func(key K, value V, reason EvictReason) {
+	return s.shards[0].OnEvict()
+}
+
+// SetLoader sets the loader used by every shard.
+func (s *ShardedCacheMap[K, V]) SetLoader(loader CacheLoader[K, V]) *ShardedCacheMap[K, V] {
+	for _, shard := range s.shards {
+		shard.SetLoader(loader)
+	}
+	return s
+}
+
+func (s *ShardedCacheMap[K, V]) Loader() CacheLoader[K, V] {
+	return s.shards[0].Loader()
+}
+
+// SetEvictionPolicy gives every shard of a bounded cache its own policy
+// made by newPolicy, see CacheMap.SetEvictionPolicy. Policies implementing
+// EvictionPeeker let the shards compare their candidates before evicting.
+func (s *ShardedCacheMap[K, V]) SetEvictionPolicy(newPolicy func() EvictionPolicy[K]) *ShardedCacheMap[K, V] {
+	for _, shard := range s.shards {
+		shard.SetEvictionPolicy(newPolicy())
+	}
+	return s
+}
+
+// SetSizer sets the func computing entry costs in every shard.
+func (s *ShardedCacheMap[K, V]) SetSizer(fn func(key K, value V) int64) *ShardedCacheMap[K, V] {
+	for _, shard := range s.shards {
+		shard.SetSizer(fn)
+	}
+	return s
+}
+
+func (s *ShardedCacheMap[K, V]) Sizer() func(key K, value V) int64 {
+	return s.shards[0].Sizer()
+}
+
+// Stats returns the hit, miss and eviction counts, the cost and the load
+// waiters summed over all shards.
+func (s *ShardedCacheMap[K, V]) Stats() CacheStats {
+	var stats CacheStats
+	for _, shard := range s.shards {
+		st := shard.Stats()
+		stats.hits += st.hits
+		stats.misses += st.misses
+		stats.evictions += st.evictions
+		stats.cost += st.cost
+		stats.waiting += st.waiting
+	}
+	return stats
+}
+
+func (s *ShardedCacheMap[K, V]) DeleteExpired() (deleted []K) {
+	for _, shard := range s.shards {
+		deleted = append(deleted, shard.DeleteExpired()...)
+	}
+	return deleted
+}
+
+func (s *ShardedCacheMap[K, V]) Add(key K, value V) {
+	s.shard(key).Add(key, value)
+}
+
+func (s *ShardedCacheMap[K, V]) AddWithExpiry(key K, value V, dur time.Duration) {
+	s.shard(key).AddWithExpiry(key, value, dur)
+}
+
+func (s *ShardedCacheMap[K, V]) AddWithCost(key K, value V, cost int64) {
+	s.shard(key).AddWithCost(key, value, cost)
+}
+
+func (s *ShardedCacheMap[K, V]) Delete(keys ...K) {
+	for _, key := range keys {
+		s.shard(key).Delete(key)
+	}
+}
+
+func (s *ShardedCacheMap[K, V]) Has(key K) bool {
+	return s.shard(key).Has(key)
+}
+
+// Len returns the number of entries that haven't expired. Shards are counted
+// one after another, so with concurrent writers the result is approximate.
+func (s *ShardedCacheMap[K, V]) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+func (s *ShardedCacheMap[K, V]) Keys() []K {
+	var keys []K
+	for _, shard := range s.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+	return keys
+}
+
+func (s *ShardedCacheMap[K, V]) Vals() []V {
+	var vals []V
+	for _, shard := range s.shards {
+		vals = append(vals, shard.Vals()...)
+	}
+	return vals
+}
+
+func (s *ShardedCacheMap[K, V]) Get(key K) (value V, ok bool) {
+	return s.shard(key).Get(key)
+}
+
+func (s *ShardedCacheMap[K, V]) GetOrLoad(ctx context.Context, key K, loader CacheLoader[K, V]) (value V, err error) {
+	return s.shard(key).GetOrLoad(ctx, key, loader)
+}
+
+func (s *ShardedCacheMap[K, V]) ExpiresAt(key K) (expiry time.Time, ok bool) {
+	return s.shard(key).ExpiresAt(key)
+}
+
+func (s *ShardedCacheMap[K, V]) TTL(key K) (ttl time.Duration, ok bool) {
+	return s.shard(key).TTL(key)
+}
+
+func (s *ShardedCacheMap[K, V]) Touch(key K) bool {
+	return s.shard(key).Touch(key)
+}
+
+func (s *ShardedCacheMap[K, V]) Extend(key K, d time.Duration) bool {
+	return s.shard(key).Extend(key, d)
+}
+
+func (s *ShardedCacheMap[K, V]) Persist(key K) bool {
+	return s.shard(key).Persist(key)
+}
+
+func (s *ShardedCacheMap[K, V]) Clear() {
+	for _, shard := range s.shards {
+		shard.Clear()
+	}
+}
+
+// Close stops the janitors of all shards.
+func (s *ShardedCacheMap[K, V]) Close() {
+	for _, shard := range s.shards {
+		shard.Close()
+	}
+}
+
+// All returns an iterator over the key-value pairs of the cache with the
+// same semantics as CacheMap.All, one shard at a time.
+func (s *ShardedCacheMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, shard := range s.shards {
+			for k, v := range shard.All() {
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the values of the cache.
+func (s *ShardedCacheMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range s.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON encodes the cache in the same format as CacheMap.
+func (s *ShardedCacheMap[K, V]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.entries())
+}
+
+// UnmarshalJSON replaces the cache contents. Each entry expires after its
+// encoded remaining TTL.
+func (s *ShardedCacheMap[K, V]) UnmarshalJSON(data []byte) error {
+	var entries []cacheMapEntry[K, V]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	s.reset(entries)
+	return nil
+}
+
+// MarshalBinary encodes the cache with encoding/gob, keeping remaining TTLs.
+func (s *ShardedCacheMap[K, V]) MarshalBinary() ([]byte, error) {
+	return gobEncode(s.entries())
+}
+
+// UnmarshalBinary replaces the cache contents with gob encoded entries.
+func (s *ShardedCacheMap[K, V]) UnmarshalBinary(data []byte) error {
+	var entries []cacheMapEntry[K, V]
+	if err := gobDecode(data, &entries); err != nil {
+		return err
+	}
+	s.reset(entries)
+	return nil
+}
+
+func (s *ShardedCacheMap[K, V]) entries() []cacheMapEntry[K, V] {
+	var entries []cacheMapEntry[K, V]
+	for _, shard := range s.shards {
+		entries = append(entries, shard.entries()...)
+	}
+	return entries
+}
+
+func (s *ShardedCacheMap[K, V]) reset(entries []cacheMapEntry[K, V]) {
+	if s.shards == nil {
+		*s = *NewShardedCacheMap[K, V](0)
+	}
+	s.Clear()
+	byShard := map[*CacheMap[K, V]][]cacheMapEntry[K, V]{}
+	for _, e := range entries {
+		shard := s.shard(e.Key)
+		byShard[shard] = append(byShard[shard], e)
+	}
+	for shard, entries := range byShard {
+		shard.restore(entries)
+	}
+}
+
+func (s *ShardedCacheMap[K, V]) shard(k K) *CacheMap[K, V] {
+	return s.shards[s.hash(k)&uint64(len(s.shards)-1)]
+}
+
+// moveTo moves key with its expiry from c to dst without calling OnEvict,
+// unless dst has to evict to make room. Both caches must be locked.
+func (c *CacheMap[K, V]) moveTo(key K, dst *CacheMap[K, V]) {
+	value, expiry := c.items[key], c.itemExpiries[key]
+	ttl, custom := c.ttls[key]
+	cost, used := c.costs[key], c.used[key]
+	delete(c.items, key)
+	delete(c.itemExpiries, key)
+	delete(c.ttls, key)
+	c.dropCost(key)
+	c.janitor.unschedule(key)
+	if c.policy != nil {
+		c.policy.Remove(key)
+	}
+	dst.items[key] = value
+	dst.itemExpiries[key] = expiry
+	if custom {
+		dst.ttls[key] = ttl
+	}
+	dst.setCost(key, cost)
+	dst.janitor.schedule(key, expiry)
+	if dst.policy != nil {
+		dst.policy.Add(key)
+		if dst.used == nil {
+			dst.used = map[K]uint64{}
+		}
+		dst.used[key] = used
+		dst.evictOverCapacity()
+	}
+}
+
+// shardSamples is how many shards compare their eviction candidates before
+// a ShardedCacheMap evicts one.
+const shardSamples = 8
+
+// shardBudget counts the entries and cost of all shards of a
+// ShardedCacheMap against its MaxEntries and MaxCost. The shards update it
+// with their own lock held. clock orders uses across shards.
+type shardBudget[K comparable, V any] struct {
+	shards     []*CacheMap[K, V]
+	maxEntries int
+	maxCost    int64
+	entries    atomic.Int64
+	cost       atomic.Int64
+	clock      atomic.Uint64
+	next       atomic.Uint64
+}
+
+// track adds entries and cost to the totals. It does nothing on a nil budget.
+func (b *shardBudget[K, V]) track(entries int, cost int64) {
+	if b != nil {
+		b.entries.Add(int64(entries))
+		b.cost.Add(cost)
+	}
+}
+
+func (b *shardBudget[K, V]) over() bool {
+	return (b.maxEntries >= 0 && b.entries.Load() > int64(b.maxEntries)) ||
+		(b.maxCost >= 0 && b.cost.Load() > b.maxCost)
+}
+
+// enforce evicts entries until the shards fit together. It must be called
+// without any shard locked.
+func (b *shardBudget[K, V]) enforce() {
+	if b == nil {
+		return
+	}
+	for empty := 0; b.over() && empty < len(b.shards); {
+		if b.coldest().evictOne() {
+			empty = 0
+		} else {
+			empty++
+		}
+	}
+}
+
+// coldest samples up to shardSamples shards and returns the one whose
+// eviction candidate was used least recently. Policies that can't peek
+// leave the shards to take turns.
+func (b *shardBudget[K, V]) coldest() *CacheMap[K, V] {
+	n := uint64(len(b.shards))
+	samples := min(n, shardSamples)
+	start := b.next.Add(1)
+	var coldest *CacheMap[K, V]
+	var coldestUsed uint64
+	for i := range samples {
+		shard := b.shards[(start+i)%n]
+		used, ok := shard.candidate()
+		if ok && (coldest == nil || used < coldestUsed) {
+			coldest, coldestUsed = shard, used
+		}
+	}
+	if coldest == nil {
+		return b.shards[start%n]
+	}
+	return coldest
+}
@@ -0,0 +1,236 @@
+package structures_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestShardedCacheMap(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewShardedCacheMap[int, int](time.Second,
+		structures.ShardsCacheOpt(4),
+		structures.AutoDeleteCacheOpt(),
+		structures.ClockCacheOpt(clock),
+	)
+	is.Equal(cache.Shards(), 4)
+	for i := range 100 {
+		cache.Add(i, i)
+	}
+	cache.AddWithExpiry(100, 100, time.Minute)
+	is.Equal(cache.Len(), 101)
+	is.True(clock.PendingTimers() > 1) // one janitor per shard
+
+	v, ok := cache.Get(7)
+	is.True(ok)
+	is.Equal(v, 7)
+	_, ok = cache.Get(1000)
+	is.True(!ok)
+	is.Equal(cache.Stats().Hits(), 1)
+	is.Equal(cache.Stats().Misses(), 1)
+
+	clock.Advance(time.Second)
+	is.Equal(cache.Len(), 1)
+	is.Equal(cache.Keys(), []int{100})
+	cache.Close()
+	is.Equal(clock.PendingTimers(), 0)
+}
+
+func TestShardedCacheMapMaxEntries(t *testing.T) {
+	is := is.New(t)
+	evicted := 0
+	cache := structures.NewShardedCacheMap[int, int](time.Hour,
+		structures.ShardsCacheOpt(64),
+		structures.MaxEntriesCacheOpt(10),
+	)
+	cache.SetOnEvict(func(int, int, structures.EvictReason) { evicted++ })
+	for i := range 10_000 {
+		cache.Add(i, i)
+	}
+	// The bound holds for the whole cache, not per shard
+	is.Equal(cache.Len(), 10)
+	is.Equal(evicted, 10_000-10)
+	is.Equal(cache.Stats().Evictions(), evicted)
+
+	cache.Delete(cache.Keys()[0])
+	cache.Clear()
+	for i := range 10 {
+		cache.Add(i, i)
+	}
+	is.Equal(cache.Len(), 10)
+}
+
+func TestShardedCacheMapEvictsColdest(t *testing.T) {
+	for _, policy := range []struct {
+		name string
+		new  func() structures.EvictionPolicy[int]
+	}{
+		{"LRU", func() structures.EvictionPolicy[int] { return structures.NewLRUPolicy[int]() }},
+		{"LFU", func() structures.EvictionPolicy[int] { return structures.NewLFUPolicy[int]() }},
+	} {
+		t.Run(policy.name, func(t *testing.T) {
+			is := is.New(t)
+			// With up to 8 shards every shard's candidate is compared
+			cache := structures.NewShardedCacheMap[int, int](time.Hour,
+				structures.ShardsCacheOpt(8),
+				structures.MaxEntriesCacheOpt(8),
+			).SetEvictionPolicy(policy.new)
+			for i := range 8 {
+				cache.Add(i, i)
+			}
+			for range 3 {
+				for i := range 4 {
+					cache.Get(i)
+				}
+			}
+			for i := 8; i < 12; i++ {
+				cache.Add(i, i)
+			}
+			keys := cache.Keys()
+			slices.Sort(keys)
+			is.Equal(keys, []int{0, 1, 2, 3, 8, 9, 10, 11})
+		})
+	}
+}
+
+func TestShardedCacheMapMaxEntriesConcurrent(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewShardedCacheMap[int, int](time.Hour,
+		structures.ShardsCacheOpt(8),
+		structures.MaxEntriesCacheOpt(100),
+	)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				cache.Add(g*1000+i, i)
+			}
+		}()
+	}
+	wg.Wait()
+	is.True(cache.Len() <= 100)
+	is.True(cache.Len() >= 90) // only a few extra evictions from racing writers
+}
+
+func TestShardedCacheMapMaxCost(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewShardedCacheMap[int, string](time.Hour,
+		structures.ShardsCacheOpt(64),
+		structures.MaxCostCacheOpt(100<<20),
+	).SetSizer(func(k int, v string) int64 { return int64(len(v)) })
+	cache.AddWithCost(0, "big", 5<<20) // far more than 1/64 of the budget
+	is.True(cache.Has(0))
+
+	for i := 1; i <= 100; i++ {
+		cache.AddWithCost(i, "", 2<<20)
+	}
+	is.Equal(cache.Stats().Cost(), int64(100<<20)) // the budget is filled, not exceeded
+	cache.AddWithCost(1000, "", 101<<20)           // more than the whole budget
+	is.True(!cache.Has(1000))
+	is.Equal(cache.Stats().Cost(), int64(100<<20))
+
+	cache.Clear()
+	for i := range 100 {
+		cache.Add(i, strings.Repeat("x", 10))
+	}
+	is.Equal(cache.Stats().Cost(), int64(1000))
+	cache.SetHasher(func(k int) uint64 { return uint64(k) })
+	is.Equal(cache.Stats().Cost(), int64(1000))
+}
+
+func TestShardedCacheMapSetHasher(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewShardedCacheMap[string, int](time.Second, structures.ClockCacheOpt(clock))
+	cache.Add("a", 1)
+	cache.AddWithExpiry("b", 2, time.Minute)
+	cache.SetHasher(func(k string) uint64 { return uint64(len(k)) })
+	ttl, ok := cache.TTL("b")
+	is.True(ok)
+	is.Equal(ttl, time.Minute)
+	cache.Touch("b") // the custom TTL moved along with the entry
+	ttl, _ = cache.TTL("b")
+	is.Equal(ttl, time.Minute)
+	clock.Advance(time.Second)
+	is.True(!cache.Has("a"))
+	is.True(cache.Has("b"))
+}
+
+func TestShardedCacheMapLoadAndEncode(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewShardedCacheMap[int, string](time.Minute)
+	cache.SetLoader(func(ctx context.Context, k int) (string, time.Duration, error) {
+		return strconv.Itoa(k), time.Hour, nil
+	})
+	v, err := cache.GetOrLoad(context.Background(), 3, nil)
+	is.NoErr(err)
+	is.Equal(v, "3")
+	cache.Add(4, "4")
+	cache.Persist(4)
+
+	data, err := json.Marshal(cache)
+	is.NoErr(err)
+	decoded := structures.NewShardedCacheMap[int, string](time.Minute)
+	is.NoErr(json.Unmarshal(data, decoded))
+	is.Equal(decoded.Len(), 2)
+	ttl, _ := decoded.TTL(4)
+	is.Equal(ttl, structures.NoTTL)
+}
+
+// BenchmarkCacheMapParallel compares read-mostly throughput of CacheMap and
+// ShardedCacheMap with every CPU hitting the cache at once.
+func BenchmarkCacheMapParallel(b *testing.B) {
+	const keys = 1 << 16
+	type cacheLike interface {
+		Get(int) (int, bool)
+		Add(int, int)
+	}
+	for _, bounded := range []bool{false, true} {
+		var opts []structures.CacheOpt
+		if bounded {
+			opts = append(opts, structures.MaxEntriesCacheOpt(keys))
+		}
+		impls := []struct {
+			name  string
+			cache cacheLike
+		}{
+			{"CacheMap", structures.NewCacheMap[int, int](time.Hour, opts...)},
+			{"ShardedCacheMap", structures.NewShardedCacheMap[int, int](time.Hour, opts...)},
+		}
+		for _, impl := range impls {
+			for i := range keys {
+				impl.cache.Add(i, i)
+			}
+			name := impl.name
+			if bounded {
+				name += "/bounded"
+			}
+			b.Run(name, func(b *testing.B) {
+				b.RunParallel(func(pb *testing.PB) {
+					i := rand.IntN(keys)
+					for pb.Next() {
+						k := (i * 7919) & (keys - 1)
+						if i%10 == 0 {
+							impl.cache.Add(k, i)
+						} else {
+							impl.cache.Get(k)
+						}
+						i++
+					}
+				})
+			})
+		}
+	}
+}
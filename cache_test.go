@@ -1,6 +1,11 @@
 package structures_test
 
 import (
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
@@ -33,9 +38,10 @@ func TestCacheContains(t *testing.T) {
 
 func TestCacheDeleteExpired(t *testing.T) {
 	is := is.New(t)
-	cache := structures.NewCache[int](time.Millisecond)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCache[int](time.Millisecond, structures.ClockCacheOpt(clock))
 	cache.Add(1, 2, 3, 4, 5)
-	time.Sleep(time.Millisecond)
+	clock.Advance(time.Millisecond)
 	deleted := cache.DeleteExpired()
 	is.Equal(len(deleted), 5)
 	is.Equal(cache.Len(), 0)
@@ -43,38 +49,338 @@ func TestCacheDeleteExpired(t *testing.T) {
 
 func TestCacheAutoDelete(t *testing.T) {
 	is := is.New(t)
-	cache := structures.NewCache[int](time.Millisecond, structures.AutoDeleteCacheOpt())
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCache[int](time.Millisecond, structures.AutoDeleteCacheOpt(), structures.ClockCacheOpt(clock))
 	cache.Add(1, 2, 3, 4, 5)
-	time.Sleep(time.Millisecond * 100)
+	clock.Advance(time.Millisecond * 100)
 	is.Equal(cache.Len(), 0)
 }
 
 func TestCacheAutoDeleteWithRenewedItems(t *testing.T) {
 	is := is.New(t)
-	cache := structures.NewCache[int](time.Second, structures.AutoDeleteCacheOpt())
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCache[int](time.Second, structures.AutoDeleteCacheOpt(), structures.ClockCacheOpt(clock))
 	cache.Add(1, 2, 3, 4, 5)
-	time.Sleep(time.Millisecond * 500)
+	clock.Advance(time.Millisecond * 500)
 	cache.Add(4, 5)
-	time.Sleep(time.Millisecond * 600)
+	clock.Advance(time.Millisecond * 600)
 	is.Equal(cache.Len(), 2)
-	time.Sleep(time.Millisecond * 500)
+	clock.Advance(time.Millisecond * 500)
 	is.Equal(cache.Len(), 0)
 }
 
 func TestCacheMapAutoDeleteWithRenewedItems(t *testing.T) {
 	is := is.New(t)
-	cache := structures.NewCacheMap[int, int](time.Second, structures.AutoDeleteCacheOpt())
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[int, int](time.Second, structures.AutoDeleteCacheOpt(), structures.ClockCacheOpt(clock))
 	for i := 0; i < 5; i++ {
 		cache.Add(i, i)
 	}
-	time.Sleep(time.Millisecond * 500)
+	clock.Advance(time.Millisecond * 500)
 	cache.Add(4, 4)
 	cache.AddWithExpiry(5, 5, time.Minute)
-	time.Sleep(time.Millisecond * 600)
+	clock.Advance(time.Millisecond * 600)
 	is.Equal(cache.Len(), 2)
 	v, ok := cache.Get(4)
 	is.Equal(ok, true)
 	is.Equal(v, 4)
-	time.Sleep(time.Millisecond * 500)
+	clock.Advance(time.Millisecond * 500)
 	is.Equal(cache.Len(), 1)
 }
+
+func TestCacheAutoDeleteSingleTimer(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[int, int](time.Second, structures.AutoDeleteCacheOpt(), structures.ClockCacheOpt(clock))
+	for i := range 1000 {
+		cache.AddWithExpiry(i, i, time.Duration(1000-i)*time.Millisecond)
+	}
+	is.Equal(clock.PendingTimers(), 1)
+
+	cache.Delete(999) // earliest expiry, the timer may wake up early
+	clock.Advance(time.Millisecond)
+	is.Equal(cache.Len(), 999)
+	clock.Advance(499 * time.Millisecond)
+	is.Equal(cache.Len(), 500)
+	clock.Advance(500 * time.Millisecond)
+	is.Equal(cache.Len(), 0)
+	is.Equal(clock.PendingTimers(), 0)
+}
+
+func TestCacheClose(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCache[int](time.Second, structures.AutoDeleteCacheOpt(), structures.ClockCacheOpt(clock))
+	cache.Add(1, 2)
+	cache.Close()
+	cache.Close()
+	is.Equal(clock.PendingTimers(), 0)
+	cache.Add(3)
+	clock.Advance(time.Second)
+	is.Equal(cache.Len(), 0) // expired keys are hidden but not deleted
+	is.Equal(len(cache.DeleteExpired()), 3)
+}
+
+func TestCacheLazyExpiry(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCache[int](time.Second, structures.ClockCacheOpt(clock))
+	cache.Add(1, 2)
+	cache.AddWithExpiry(3, time.Minute)
+	clock.Advance(time.Second)
+	is.True(!cache.Contains(1))
+	is.True(cache.Contains(3))
+	is.Equal(cache.Len(), 1)
+	is.Equal(cache.Keys(), []int{3})
+	is.Equal(len(cache.DeleteExpired()), 2) // hidden, not deleted
+
+	cm := structures.NewCacheMap[int, string](time.Second, structures.ClockCacheOpt(clock))
+	cm.Add(1, "a")
+	cm.AddWithExpiry(2, "b", time.Minute)
+	clock.Advance(time.Second)
+	_, ok := cm.Get(1)
+	is.True(!ok)
+	is.True(!cm.Has(1))
+	is.Equal(cm.Len(), 1)
+	is.Equal(cm.Keys(), []int{2})
+	is.Equal(cm.Vals(), []string{"b"})
+	is.Equal(maps.Collect(cm.All()), map[int]string{2: "b"})
+}
+
+func TestCacheMapEvictOnRead(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[int, int](time.Second, structures.EvictOnReadCacheOpt(), structures.ClockCacheOpt(clock))
+	cache.Add(1, 1)
+	cache.Add(2, 2)
+	clock.Advance(time.Second)
+	is.True(!cache.Has(1))
+	is.Equal(cache.DeleteExpired(), []int{2}) // 1 was already evicted by Has
+}
+
+func TestCacheMapMaxEntries(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[int, int](time.Second,
+		structures.MaxEntriesCacheOpt(3),
+		structures.AutoDeleteCacheOpt(),
+		structures.ClockCacheOpt(clock),
+	)
+	cache.Add(1, 1)
+	cache.Add(2, 2)
+	cache.Add(3, 3)
+	cache.Get(1)
+	cache.Has(2) // doesn't count as a use
+	cache.Add(4, 4)
+	is.Equal(slices.Sorted(slices.Values(cache.Keys())), []int{1, 3, 4})
+
+	cache.Add(3, 30) // renewing doesn't evict
+	is.Equal(cache.Len(), 3)
+	cache.Delete(1)
+	cache.Add(5, 5)
+	is.Equal(slices.Sorted(slices.Values(cache.Keys())), []int{3, 4, 5})
+
+	clock.Advance(time.Second)
+	is.Equal(cache.Len(), 0)
+	for i := range 10 {
+		cache.Add(i, i)
+	}
+	is.Equal(slices.Sorted(slices.Values(cache.Keys())), []int{7, 8, 9})
+}
+
+func TestCacheMapMaxCost(t *testing.T) {
+	is := is.New(t)
+	var evicted []string
+	cache := structures.NewCacheMap[string, []byte](time.Hour, structures.MaxCostCacheOpt(100))
+	cache.SetSizer(func(k string, v []byte) int64 { return int64(len(v)) })
+	cache.SetOnEvict(func(k string, v []byte, reason structures.EvictReason) {
+		evicted = append(evicted, k+" "+reason.String())
+	})
+	cache.Add("a", make([]byte, 40))
+	cache.Add("b", make([]byte, 40))
+	is.Equal(cache.Stats().Cost(), int64(80))
+	cache.Get("a")
+	cache.Add("c", make([]byte, 40)) // least recently used "b" makes room
+	is.Equal(slices.Sorted(slices.Values(cache.Keys())), []string{"a", "c"})
+	is.Equal(cache.Stats().Cost(), int64(80))
+
+	cache.AddWithCost("d", nil, 90) // explicit cost wins over the sizer
+	is.Equal(cache.Keys(), []string{"d"})
+	cache.Add("d", make([]byte, 10)) // replacing updates the cost
+	is.Equal(cache.Stats().Cost(), int64(10))
+	cache.AddWithCost("e", nil, 500) // never fits
+	is.True(!cache.Has("e"))
+	is.Equal(evicted, []string{"b evicted", "a evicted", "c evicted", "d replaced", "e evicted"})
+
+	cache.Delete("d")
+	is.Equal(cache.Stats().Cost(), int64(0))
+	is.Equal(cache.Stats().Evictions(), 4)
+}
+
+func TestCacheMapMaxCostAndEntries(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewCacheMap[int, int](time.Hour,
+		structures.MaxCostCacheOpt(10),
+		structures.MaxEntriesCacheOpt(3),
+		structures.EvictionCacheOpt(structures.EvictionLFU),
+	)
+	for i := range 5 {
+		cache.Add(i, i) // default cost is 1, so the entry limit applies
+	}
+	is.Equal(slices.Sorted(slices.Values(cache.Keys())), []int{2, 3, 4})
+	is.Equal(cache.Stats().Cost(), int64(3))
+	cache.Get(4)
+	cache.Get(4)
+	// 9 only fits once the two least frequently used entries are gone
+	cache.AddWithCost(9, 9, 9)
+	is.Equal(slices.Sorted(slices.Values(cache.Keys())), []int{4, 9})
+	is.Equal(cache.Stats().Cost(), int64(10))
+	is.Equal(cache.Stats().Evictions(), 4)
+	cache.Clear()
+	is.Equal(cache.Stats().Cost(), int64(0))
+}
+
+func TestCacheMapOnEvict(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[string, int](time.Second,
+		structures.MaxEntriesCacheOpt(2),
+		structures.AutoDeleteCacheOpt(),
+		structures.ClockCacheOpt(clock),
+	)
+	var got []string
+	cache.SetOnEvict(func(key string, value int, reason structures.EvictReason) {
+		cache.Len() // the lock is released, calling back in doesn't deadlock
+		got = append(got, fmt.Sprintf("%s=%d %s", key, value, reason))
+	})
+	cache.Add("a", 1)
+	cache.Add("a", 2)
+	cache.Add("b", 1)
+	cache.Add("c", 1)
+	cache.Delete("b")
+	clock.Advance(time.Second)
+	cache.Add("d", 1)
+	cache.Clear()
+	is.Equal(got, []string{
+		"a=1 replaced",
+		"a=2 evicted",
+		"b=1 deleted",
+		"c=1 expired",
+		"d=1 cleared",
+	})
+}
+
+func TestCacheOnEvict(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCache[int](time.Second, structures.ClockCacheOpt(clock))
+	reasons := map[int]structures.EvictReason{}
+	cache.SetOnEvict(func(key int, reason structures.EvictReason) {
+		reasons[key] = reason
+	})
+	cache.Add(1, 2, 3)
+	cache.Delete(1)
+	clock.Advance(time.Second)
+	cache.Add(2) // 2 had expired
+	cache.DeleteExpired()
+	is.Equal(reasons, map[int]structures.EvictReason{
+		1: structures.EvictDeleted,
+		2: structures.EvictExpired,
+		3: structures.EvictExpired,
+	})
+}
+
+func TestCacheMapAll(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewCacheMap[string, int](time.Minute)
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+	is.Equal(maps.Collect(cache.All()), map[string]int{"a": 1, "b": 2})
+	is.Equal(slices.Sorted(cache.Values()), []int{1, 2})
+	for k := range cache.All() {
+		cache.Delete(k)
+	}
+	is.Equal(cache.Len(), 0)
+
+	keys := structures.NewCache[int](time.Minute)
+	keys.Add(1, 2, 3)
+	is.Equal(slices.Sorted(maps.Keys(maps.Collect(keys.All()))), []int{1, 2, 3})
+}
+
+// sleeperCache is the old auto delete design, one sleeping goroutine per
+// added key, kept to compare against the janitor. stop releases the
+// sleepers so the benchmark doesn't leak them.
+type sleeperCache[K comparable] struct {
+	items   map[K]time.Time
+	cancels map[K]*bool
+	stop    chan struct{}
+	mu      sync.Mutex
+}
+
+func (c *sleeperCache[K]) AddWithExpiry(key K, dur time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = time.Now().Add(dur)
+	if canceled, ok := c.cancels[key]; ok {
+		*canceled = true
+	}
+	canceled := new(bool)
+	c.cancels[key] = canceled
+	go func() {
+		select {
+		case <-time.After(dur):
+		case <-c.stop:
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !*canceled {
+			delete(c.items, key)
+			delete(c.cancels, key)
+		}
+	}()
+}
+
+// BenchmarkCacheAutoDelete fills a cache with keys that stay alive for the
+// whole iteration and reports the memory and goroutines they hold on to.
+func BenchmarkCacheAutoDelete(b *testing.B) {
+	const ttl = time.Hour
+	run := func(b *testing.B, n int, fill func() (add func(int), stop func())) {
+		b.ReportAllocs()
+		var live, goroutines float64
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			g := runtime.NumGoroutine()
+			b.StartTimer()
+			add, stop := fill()
+			for k := 0; k < n; k++ {
+				add(k)
+			}
+			b.StopTimer()
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			live = (float64(after.HeapInuse+after.StackInuse) - float64(before.HeapInuse+before.StackInuse)) / float64(n)
+			goroutines = float64(runtime.NumGoroutine()-g) / float64(n)
+			stop()
+		}
+		b.ReportMetric(live, "live-B/key")
+		b.ReportMetric(goroutines, "goroutines/key")
+	}
+	for _, n := range []int{1_000, 100_000} {
+		b.Run(fmt.Sprintf("janitor/%d", n), func(b *testing.B) {
+			run(b, n, func() (func(int), func()) {
+				cache := structures.NewCache[int](ttl, structures.AutoDeleteCacheOpt())
+				return func(k int) { cache.AddWithExpiry(k, ttl) }, cache.Close
+			})
+		})
+		b.Run(fmt.Sprintf("goroutine/%d", n), func(b *testing.B) {
+			run(b, n, func() (func(int), func()) {
+				cache := &sleeperCache[int]{items: map[int]time.Time{}, cancels: map[int]*bool{}, stop: make(chan struct{})}
+				return func(k int) { cache.AddWithExpiry(k, ttl) }, func() { close(cache.stop) }
+			})
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package structures
+
+import "time"
+
+// NoTTL is returned by TTL for entries made permanent with Persist.
+const NoTTL time.Duration = -1
+
+// never is the expiry of persisted entries.
+var never = time.Unix(1<<62, 0)
+
+// ExpiresAt returns when key expires, or the zero time if it was persisted.
+// It returns false if key isn't in the cache or has expired.
+func (c *Cache[K]) ExpiresAt(key K) (expiry time.Time, ok bool) {
+	now := c.opts.Clock.Now()
+	c.mu.RLock()
+	expiry, ok = c.items[key]
+	c.mu.RUnlock()
+	if !ok || expired(expiry, now) {
+		return time.Time{}, false
+	}
+	if expiry.Equal(never) {
+		return time.Time{}, true
+	}
+	return expiry, true
+}
+
+// TTL returns how long until key expires, or NoTTL if it was persisted. It
+// returns false if key isn't in the cache or has expired.
+func (c *Cache[K]) TTL(key K) (ttl time.Duration, ok bool) {
+	expiry, ok := c.ExpiresAt(key)
+	if !ok {
+		return 0, false
+	}
+	return remainingTTL(expiry, c.opts.Clock.Now()), true
+}
+
+// Touch restarts key's TTL, the one it was added with, from now. Persisted
+// keys stay persisted. It returns false if key isn't in the cache or has
+// expired.
+func (c *Cache[K]) Touch(key K) bool {
+	return c.updateExpiry(key, func(now, expiry time.Time) time.Time {
+		ttl, ok := c.ttls[key]
+		if !ok {
+			ttl = c.expiry
+		}
+		return now.Add(ttl)
+	})
+}
+
+// Extend pushes key's expiry back by d. It returns false if key isn't in the
+// cache or has expired.
+func (c *Cache[K]) Extend(key K, d time.Duration) bool {
+	return c.updateExpiry(key, func(now, expiry time.Time) time.Time {
+		return expiry.Add(d)
+	})
+}
+
+// Persist makes key permanent until it is deleted or added again. It
+// returns false if key isn't in the cache or has expired.
+func (c *Cache[K]) Persist(key K) bool {
+	return c.updateExpiry(key, func(now, expiry time.Time) time.Time {
+		return never
+	})
+}
+
+// updateExpiry replaces the expiry of a live, non persisted key with
+// update's result.
+func (c *Cache[K]) updateExpiry(key K, update func(now, expiry time.Time) time.Time) bool {
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiry, ok := c.items[key]
+	if !ok || expired(expiry, now) {
+		return false
+	}
+	if expiry.Equal(never) {
+		return true
+	}
+	expiry = update(now, expiry)
+	c.items[key] = expiry
+	if expiry.Equal(never) {
+		c.janitor.unschedule(key)
+	} else {
+		c.janitor.schedule(key, expiry)
+	}
+	return true
+}
+
+// ExpiresAt returns when key expires, or the zero time if it was persisted.
+// With StaleTTLCacheOpt an expired entry is still returned until its stale
+// window ends. It returns false if key isn't in the cache or is gone.
+func (c *CacheMap[K, V]) ExpiresAt(key K) (expiry time.Time, ok bool) {
+	now := c.opts.Clock.Now()
+	c.mu.RLock()
+	_, ok = c.items[key]
+	expiry = c.itemExpiries[key]
+	c.mu.RUnlock()
+	if !ok || expired(expiry, now) {
+		return time.Time{}, false
+	}
+	if expiry.Equal(never) {
+		return time.Time{}, true
+	}
+	return expiry.Add(-c.staleTTL()), true
+}
+
+// TTL returns how long until key expires, 0 if it is stale, or NoTTL if it
+// was persisted. It returns false if key isn't in the cache or is gone.
+func (c *CacheMap[K, V]) TTL(key K) (ttl time.Duration, ok bool) {
+	expiry, ok := c.ExpiresAt(key)
+	if !ok {
+		return 0, false
+	}
+	return remainingTTL(expiry, c.opts.Clock.Now()), true
+}
+
+// Touch restarts key's TTL, the one it was added or loaded with, from now.
+// Persisted keys stay persisted. It returns false if key isn't in the cache
+// or is gone.
+func (c *CacheMap[K, V]) Touch(key K) bool {
+	return c.updateExpiry(key, func(now, expiry time.Time) time.Time {
+		ttl, ok := c.ttls[key]
+		if !ok {
+			ttl = c.expiry
+		}
+		return now.Add(ttl + c.staleTTL())
+	})
+}
+
+// Extend pushes key's expiry back by d. It returns false if key isn't in the
+// cache or is gone.
+func (c *CacheMap[K, V]) Extend(key K, d time.Duration) bool {
+	return c.updateExpiry(key, func(now, expiry time.Time) time.Time {
+		return expiry.Add(d)
+	})
+}
+
+// Persist makes key permanent until it is deleted, evicted or added again.
+// It returns false if key isn't in the cache or is gone.
+func (c *CacheMap[K, V]) Persist(key K) bool {
+	return c.updateExpiry(key, func(now, expiry time.Time) time.Time {
+		return never
+	})
+}
+
+// updateExpiry replaces the deletion time of a live, non persisted key with
+// update's result.
+func (c *CacheMap[K, V]) updateExpiry(key K, update func(now, expiry time.Time) time.Time) bool {
+	now := c.opts.Clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiry, ok := c.itemExpiries[key]
+	if !ok || expired(expiry, now) {
+		return false
+	}
+	if expiry.Equal(never) {
+		return true
+	}
+	expiry = update(now, expiry)
+	c.itemExpiries[key] = expiry
+	if expiry.Equal(never) {
+		c.janitor.unschedule(key)
+	} else {
+		c.janitor.schedule(key, expiry)
+	}
+	return true
+}
+
+// remainingTTL returns the time from now until expiry, where the zero time
+// means persisted.
+func remainingTTL(expiry, now time.Time) time.Duration {
+	if expiry.IsZero() {
+		return NoTTL
+	}
+	return max(expiry.Sub(now), 0)
+}
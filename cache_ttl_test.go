@@ -0,0 +1,87 @@
+package structures_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestCacheMapTTL(t *testing.T) {
+	is := is.New(t)
+	start := time.Now()
+	clock := structures.NewFakeClock(start)
+	cache := structures.NewCacheMap[string, int](time.Minute,
+		structures.AutoDeleteCacheOpt(),
+		structures.ClockCacheOpt(clock),
+	)
+	cache.Add("a", 1)
+	cache.AddWithExpiry("b", 2, time.Second)
+
+	at, ok := cache.ExpiresAt("a")
+	is.True(ok)
+	is.True(at.Equal(start.Add(time.Minute)))
+	ttl, _ := cache.TTL("b")
+	is.Equal(ttl, time.Second)
+	_, ok = cache.TTL("missing")
+	is.True(!ok)
+
+	clock.Advance(500 * time.Millisecond)
+	is.True(cache.Touch("b")) // restarts b's own TTL, not the default
+	ttl, _ = cache.TTL("b")
+	is.Equal(ttl, time.Second)
+
+	is.True(cache.Extend("b", time.Second))
+	ttl, _ = cache.TTL("b")
+	is.Equal(ttl, 2*time.Second)
+
+	is.True(cache.Persist("a"))
+	ttl, _ = cache.TTL("a")
+	is.Equal(ttl, structures.NoTTL)
+	at, _ = cache.ExpiresAt("a")
+	is.True(at.IsZero())
+
+	clock.Advance(time.Hour)
+	is.True(cache.Has("a"))
+	is.True(!cache.Has("b"))
+	is.Equal(clock.PendingTimers(), 0) // the persisted key isn't scheduled
+	is.True(!cache.Touch("b"))
+
+	data, err := json.Marshal(cache)
+	is.NoErr(err)
+	decoded := structures.NewCacheMap[string, int](time.Minute)
+	is.NoErr(json.Unmarshal(data, decoded))
+	ttl, _ = decoded.TTL("a")
+	is.Equal(ttl, structures.NoTTL)
+}
+
+func TestCacheSlidingExpiration(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCache[string](time.Second,
+		structures.SlidingExpirationCacheOpt(),
+		structures.AutoDeleteCacheOpt(),
+		structures.ClockCacheOpt(clock),
+	)
+	cache.Add("a", "b")
+	for range 5 {
+		clock.Advance(800 * time.Millisecond)
+		is.True(cache.Contains("a"))
+	}
+	is.True(!cache.Contains("b"))
+	clock.Advance(time.Second)
+	is.Equal(cache.Len(), 0)
+
+	cm := structures.NewCacheMap[string, int](time.Second,
+		structures.SlidingExpirationCacheOpt(),
+		structures.ClockCacheOpt(clock),
+	)
+	cm.Add("a", 1)
+	clock.Advance(800 * time.Millisecond)
+	cm.Get("a")
+	clock.Advance(800 * time.Millisecond)
+	_, ok := cm.Get("a")
+	is.True(ok)
+}
@@ -1,13 +1,16 @@
 package structures
 
 import (
+	"encoding/json"
 	"fmt"
+	"iter"
 	"strings"
 )
 
 type CircularLinkedList[T comparable] struct {
 	tail *Node[T]
-	Size int
+	size int
+	pool *NodePool[T]
 }
 
 // New constructs and returns an empty circularly linked-list.
@@ -16,10 +19,22 @@ func NewCircularLinkedList[T comparable]() CircularLinkedList[T] {
 	return CircularLinkedList[T]{}
 }
 
+// SetNodePool makes the list take nodes from and return removed nodes to pool.
+// time-complexity: O(1)
+func (c *CircularLinkedList[T]) SetNodePool(pool *NodePool[T]) {
+	c.pool = pool
+}
+
+// Size returns the number of nodes in the list.
+// time-complexity: O(1)
+func (c *CircularLinkedList[T]) Size() int {
+	return c.size
+}
+
 // IsEmpty returns true if the linked-list doesn't contain any nodes.
 // time-complexity: O(1)
 func (c *CircularLinkedList[T]) IsEmpty() bool {
-	return c.Size == 0
+	return c.size == 0
 }
 
 // First returns the first element of the list. It returns false if the list is empty.
@@ -52,13 +67,12 @@ func (c *CircularLinkedList[T]) Rotate() {
 // time-complexity: O(1)
 func (c *CircularLinkedList[T]) AddFirst(data T) {
 	if c.IsEmpty() {
-		c.tail = &Node[T]{Data: data}
+		c.tail = c.pool.node(data, nil)
 		c.tail.Next = c.tail
 	} else {
-		n := Node[T]{Data: data, Next: c.tail.Next}
-		c.tail.Next = &n
+		c.tail.Next = c.pool.node(data, c.tail.Next)
 	}
-	c.Size++
+	c.size++
 }
 
 // AddLast adds a new node to the end of the list.
@@ -85,7 +99,8 @@ func (c *CircularLinkedList[T]) RemoveFirst() (val T, ok bool) {
 		c.tail.Next = head.Next
 	}
 
-	c.Size--
+	c.size--
+	c.pool.freeNode(head)
 
 	return val, true
 }
@@ -103,10 +118,16 @@ func (c *CircularLinkedList[T]) RemoveLast() (val T, ok bool) {
 	for ; current.Next != c.tail; current = current.Next {
 	}
 
-	current.Next = c.tail.Next
-	c.tail = current
+	removed := c.tail
+	if current == c.tail {
+		c.tail = nil
+	} else {
+		current.Next = c.tail.Next
+		c.tail = current
+	}
 
-	c.Size--
+	c.size--
+	c.pool.freeNode(removed)
 
 	return val, true
 }
@@ -125,7 +146,8 @@ func (c *CircularLinkedList[T]) Remove(val T) {
 			} else {
 				prev.Next = current.Next
 			}
-			c.Size--
+			c.size--
+			c.pool.freeNode(current)
 			return
 		}
 		prev = current
@@ -139,8 +161,184 @@ func (c *CircularLinkedList[T]) Remove(val T) {
 			prev.Next = current.Next
 			c.tail = prev
 		}
-		c.Size--
+		c.size--
+		c.pool.freeNode(current)
+	}
+}
+
+// At returns the element at index i. It returns false if i is out of range.
+// time-complexity: O(i)
+func (c *CircularLinkedList[T]) At(i int) (data T, ok bool) {
+	n := c.nodeAt(i)
+	if n == nil {
+		return
+	}
+	return n.Data, true
+}
+
+// IndexOf returns the index of the first occurrence of val, or -1 if it isn't in the list.
+// time-complexity: O(n)
+func (c *CircularLinkedList[T]) IndexOf(val T) int {
+	for i, v := range c.All() {
+		if v == val {
+			return i
+		}
+	}
+	return -1
+}
+
+// Contains returns true if val is in the list.
+// time-complexity: O(n)
+func (c *CircularLinkedList[T]) Contains(val T) bool {
+	return c.IndexOf(val) != -1
+}
+
+// InsertAt inserts a new node so that it ends up at index i. It returns false if i is out of range [0, Size].
+// time-complexity: O(i)
+func (c *CircularLinkedList[T]) InsertAt(i int, data T) bool {
+	switch {
+	case i < 0 || i > c.size:
+		return false
+	case i == 0:
+		c.AddFirst(data)
+	case i == c.size:
+		c.AddLast(data)
+	default:
+		c.insertAfter(c.nodeAt(i-1), data)
+	}
+	return true
+}
+
+// InsertAfter inserts a new node after the first occurrence of val. It returns false if val isn't in the list.
+// time-complexity: O(n)
+func (c *CircularLinkedList[T]) InsertAfter(val T, data T) bool {
+	if c.IsEmpty() {
+		return false
+	}
+	current := c.tail.Next
+	for i := 0; i < c.size; i, current = i+1, current.Next {
+		if current.Data == val {
+			c.insertAfter(current, data)
+			return true
+		}
+	}
+	return false
+}
+
+// RotateN rotates the list n times. A positive n moves the first n elements to the end,
+// a negative n moves the last -n elements to the beginning.
+// time-complexity: O(n mod Size)
+func (c *CircularLinkedList[T]) RotateN(n int) {
+	if c.IsEmpty() {
+		return
+	}
+	n %= c.size
+	if n < 0 {
+		n += c.size
+	}
+	for ; n > 0; n-- {
+		c.tail = c.tail.Next
+	}
+}
+
+// RemoveAll removes every occurrence of val and returns how many nodes were removed.
+// time-complexity: O(n)
+func (c *CircularLinkedList[T]) RemoveAll(val T) int {
+	return c.RemoveFunc(func(v T) bool {
+		return v == val
+	})
+}
+
+// RemoveFunc removes every element for which pred returns true and returns how many nodes were removed.
+// time-complexity: O(n)
+func (c *CircularLinkedList[T]) RemoveFunc(pred func(T) bool) (removed int) {
+	if c.IsEmpty() {
+		return
+	}
+	prev := c.tail
+	for i, size := 0, c.size; i < size; i++ {
+		current := prev.Next
+		if !pred(current.Data) {
+			prev = current
+			continue
+		}
+		prev.Next = current.Next
+		if current == c.tail {
+			c.tail = prev
+		}
+		c.size--
+		c.pool.freeNode(current)
+		removed++
+	}
+	if c.size == 0 {
+		c.tail = nil
+	}
+	return
+}
+
+// Concat moves all nodes of other to the end of the list, leaving other empty.
+// time-complexity: O(1)
+func (c *CircularLinkedList[T]) Concat(other *CircularLinkedList[T]) {
+	if other == c || other.IsEmpty() {
+		return
 	}
+	if !c.IsEmpty() {
+		head := c.tail.Next
+		c.tail.Next = other.tail.Next
+		other.tail.Next = head
+	}
+	c.tail = other.tail
+	c.size += other.size
+	*other = CircularLinkedList[T]{pool: other.pool}
+}
+
+// Splice moves all nodes of other into the list so that other's first element ends up at index i,
+// leaving other empty. It returns false if i is out of range [0, Size].
+// time-complexity: O(i)
+func (c *CircularLinkedList[T]) Splice(i int, other *CircularLinkedList[T]) bool {
+	if i < 0 || i > c.size || other == c {
+		return false
+	}
+	if other.IsEmpty() {
+		return true
+	}
+	if i == c.size {
+		c.Concat(other)
+		return true
+	}
+	prev := c.tail
+	if i > 0 {
+		prev = c.nodeAt(i - 1)
+	}
+	other.tail.Next, prev.Next = prev.Next, other.tail.Next
+	c.size += other.size
+	*other = CircularLinkedList[T]{pool: other.pool}
+	return true
+}
+
+// SplitAt splits the list into the elements before index i and the elements from index i on,
+// leaving the list empty. i is clamped to [0, Size]. No data is copied, nodes are relinked.
+// time-complexity: O(i)
+func (c *CircularLinkedList[T]) SplitAt(i int) (left CircularLinkedList[T], right CircularLinkedList[T]) {
+	i = min(max(i, 0), c.size)
+	switch i {
+	case 0:
+		right = *c
+	case c.size:
+		left = *c
+	default:
+		prev := c.nodeAt(i - 1)
+		head := c.tail.Next
+		right.tail = c.tail
+		right.tail.Next = prev.Next
+		right.size = c.size - i
+		left.tail = prev
+		left.tail.Next = head
+		left.size = i
+	}
+	left.pool, right.pool = c.pool, c.pool
+	*c = CircularLinkedList[T]{pool: c.pool}
+	return left, right
 }
 
 // String returns the string representation of the list.
@@ -181,3 +379,118 @@ func (c *CircularLinkedList[T]) String() string {
 
 	return b.String()
 }
+
+// All returns an iterator over the indexes and elements of the list, first
+// to last. The list is not safe for concurrent use and must not be modified
+// while iterating.
+// time-complexity: O(n)
+func (c *CircularLinkedList[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		if c.IsEmpty() {
+			return
+		}
+		i := 0
+		for current := c.tail.Next; ; current = current.Next {
+			if !yield(i, current.Data) || current == c.tail {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// Backward returns an iterator over the indexes and elements of the list,
+// last to first. The list is singly linked so the elements are copied to a
+// slice before the first yield.
+// time-complexity: O(n), space-complexity: O(n)
+func (c *CircularLinkedList[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		vals := c.Vals()
+		for i := len(vals) - 1; i >= 0; i-- {
+			if !yield(i, vals[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the elements of the list, first to last.
+// time-complexity: O(n)
+func (c *CircularLinkedList[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range c.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func (c *CircularLinkedList[T]) nodeAt(i int) *Node[T] {
+	if i < 0 || i >= c.size {
+		return nil
+	}
+	current := c.tail.Next
+	for ; i > 0; i-- {
+		current = current.Next
+	}
+	return current
+}
+
+func (c *CircularLinkedList[T]) insertAfter(prev *Node[T], data T) {
+	n := c.pool.node(data, prev.Next)
+	prev.Next = n
+	if prev == c.tail {
+		c.tail = n
+	}
+	c.size++
+}
+
+// MarshalJSON encodes the list as a JSON array, first to last.
+// time-complexity: O(n)
+func (c CircularLinkedList[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.slice())
+}
+
+// UnmarshalJSON replaces the list with the elements of a JSON array.
+// time-complexity: O(n)
+func (c *CircularLinkedList[T]) UnmarshalJSON(data []byte) error {
+	var vals []T
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	c.reset(vals)
+	return nil
+}
+
+// MarshalBinary encodes the list with encoding/gob.
+// time-complexity: O(n)
+func (c CircularLinkedList[T]) MarshalBinary() ([]byte, error) {
+	return gobEncode(c.slice())
+}
+
+// UnmarshalBinary replaces the list with gob encoded elements.
+// time-complexity: O(n)
+func (c *CircularLinkedList[T]) UnmarshalBinary(data []byte) error {
+	var vals []T
+	if err := gobDecode(data, &vals); err != nil {
+		return err
+	}
+	c.reset(vals)
+	return nil
+}
+
+func (c *CircularLinkedList[T]) slice() []T {
+	vals := c.Vals()
+	if vals == nil {
+		vals = []T{}
+	}
+	return vals
+}
+
+func (c *CircularLinkedList[T]) reset(vals []T) {
+	*c = CircularLinkedList[T]{pool: c.pool}
+	for _, v := range vals {
+		c.AddLast(v)
+	}
+}
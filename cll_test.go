@@ -0,0 +1,117 @@
+package structures_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func newTestCLL(vals ...int) structures.CircularLinkedList[int] {
+	list := structures.NewCircularLinkedList[int]()
+	for _, v := range vals {
+		list.AddLast(v)
+	}
+	return list
+}
+
+func TestCircularLinkedListIterators(t *testing.T) {
+	is := is.New(t)
+	list := newTestCLL(1, 2, 3)
+	is.Equal(slices.Collect(list.Values()), []int{1, 2, 3})
+
+	idxs := []int{}
+	vals := []int{}
+	for i, v := range list.Backward() {
+		idxs = append(idxs, i)
+		vals = append(vals, v)
+	}
+	is.Equal(idxs, []int{2, 1, 0})
+	is.Equal(vals, []int{3, 2, 1})
+
+	vals = vals[:0]
+	for _, v := range list.All() {
+		if v == 2 {
+			break
+		}
+		vals = append(vals, v)
+	}
+	is.Equal(vals, []int{1})
+}
+
+func TestCircularLinkedListPositional(t *testing.T) {
+	is := is.New(t)
+	list := newTestCLL(1, 2, 3)
+
+	v, ok := list.At(1)
+	is.True(ok)
+	is.Equal(v, 2)
+	_, ok = list.At(3)
+	is.True(!ok)
+	is.Equal(list.IndexOf(3), 2)
+	is.Equal(list.IndexOf(4), -1)
+	is.True(list.Contains(1))
+
+	is.True(list.InsertAt(0, 0))
+	is.True(list.InsertAt(4, 5))
+	is.True(list.InsertAt(4, 4))
+	is.True(!list.InsertAt(7, 7))
+	is.Equal(list.Vals(), []int{0, 1, 2, 3, 4, 5})
+
+	is.True(list.InsertAfter(5, 6))
+	is.True(!list.InsertAfter(9, 9))
+	last, _ := list.Last()
+	is.Equal(last, 6)
+
+	list.RotateN(2)
+	is.Equal(list.Vals(), []int{2, 3, 4, 5, 6, 0, 1})
+	list.RotateN(-3)
+	is.Equal(list.Vals(), []int{6, 0, 1, 2, 3, 4, 5})
+	list.RotateN(-15)
+	is.Equal(list.Vals(), []int{5, 6, 0, 1, 2, 3, 4})
+}
+
+func TestCircularLinkedListRemoveFunc(t *testing.T) {
+	is := is.New(t)
+	list := newTestCLL(1, 2, 1, 3, 1)
+	is.Equal(list.RemoveAll(1), 3)
+	is.Equal(list.Vals(), []int{2, 3})
+	is.Equal(list.Size(), 2)
+
+	is.Equal(list.RemoveFunc(func(v int) bool { return v > 0 }), 2)
+	is.True(list.IsEmpty())
+	list.AddLast(4)
+	is.Equal(list.Vals(), []int{4})
+}
+
+func TestCircularLinkedListSplice(t *testing.T) {
+	is := is.New(t)
+	list := newTestCLL(1, 2)
+	other := newTestCLL(3, 4)
+	list.Concat(&other)
+	is.Equal(list.Vals(), []int{1, 2, 3, 4})
+	is.Equal(list.Size(), 4)
+	is.True(other.IsEmpty())
+
+	middle := newTestCLL(8, 9)
+	is.True(list.Splice(2, &middle))
+	is.Equal(list.Vals(), []int{1, 2, 8, 9, 3, 4})
+	front := newTestCLL(0)
+	is.True(list.Splice(0, &front))
+	is.Equal(list.Vals(), []int{0, 1, 2, 8, 9, 3, 4})
+	is.Equal(list.Size(), 7)
+	is.True(!list.Splice(8, &front))
+
+	left, right := list.SplitAt(3)
+	is.Equal(left.Vals(), []int{0, 1, 2})
+	is.Equal(right.Vals(), []int{8, 9, 3, 4})
+	is.Equal(left.Size()+right.Size(), 7)
+	is.True(list.IsEmpty())
+
+	left, right = right.SplitAt(10)
+	is.Equal(left.Vals(), []int{8, 9, 3, 4})
+	is.True(right.IsEmpty())
+	left.AddLast(5)
+	is.Equal(left.Vals(), []int{8, 9, 3, 4, 5})
+}
@@ -0,0 +1,206 @@
+package structures
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for the package's time based structures.
+// Inject a FakeClock in tests to control time.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer mirrors time.Timer. C returns nil for timers created by AfterFunc.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// RealClock returns a Clock backed by the time package.
+func RealClock() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r realTimer) C() <-chan time.Time {
+	return r.t.C
+}
+
+func (r realTimer) Stop() bool {
+	return r.t.Stop()
+}
+
+func (r realTimer) Reset(d time.Duration) bool {
+	return r.t.Reset(d)
+}
+
+// FakeClock is a Clock that only moves when Advance or Set is called.
+// Timers that become due fire synchronously inside Advance, in order of
+// their deadlines, so their effects are visible once Advance returns.
+// AfterFunc callbacks must therefore not wait on the goroutine calling
+// Advance.
+type FakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+	mu     sync.Mutex
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now: now,
+	}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep blocks until the clock has been advanced by d.
+func (c *FakeClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-c.NewTimer(d).C()
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{clock: c, fn: f}
+	t.Reset(d)
+	return t
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer that becomes due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t, firing every timer that becomes due. The clock
+// never goes backwards.
+func (c *FakeClock) Set(t time.Time) {
+	for {
+		c.mu.Lock()
+		next := c.nextTimer(t)
+		if next == nil {
+			if t.After(c.now) {
+				c.now = t
+			}
+			c.mu.Unlock()
+			return
+		}
+		if next.when.After(c.now) {
+			c.now = next.when
+		}
+		c.removeTimer(next)
+		now := c.now
+		c.mu.Unlock()
+
+		if next.fn != nil {
+			next.fn()
+		} else {
+			select {
+			case next.ch <- now:
+			default:
+			}
+		}
+	}
+}
+
+// PendingTimers returns the number of timers (including sleepers) that have
+// not fired yet. Tests can poll it to know a goroutine is waiting on the clock.
+func (c *FakeClock) PendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *FakeClock) nextTimer(until time.Time) (next *fakeTimer) {
+	for _, t := range c.timers {
+		if t.when.After(until) {
+			continue
+		}
+		if next == nil || t.when.Before(next.when) {
+			next = t
+		}
+	}
+	return next
+}
+
+func (c *FakeClock) removeTimer(t *fakeTimer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	fn    func()
+	ch    chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.removeTimer(t)
+}
+
+// Reset reschedules the timer. Channel timers with d <= 0 fire right away;
+// AfterFunc timers fire on the next Advance or Set so f never runs inside
+// the caller.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.clock.removeTimer(t)
+	t.when = t.clock.now.Add(d)
+	if d <= 0 && t.ch != nil {
+		select {
+		case t.ch <- t.clock.now:
+		default:
+		}
+		return active
+	}
+	t.clock.timers = append(t.clock.timers, t)
+	return active
+}
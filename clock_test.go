@@ -0,0 +1,29 @@
+package structures_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestFakeClockTimers(t *testing.T) {
+	is := is.New(t)
+	start := time.Now()
+	clock := structures.NewFakeClock(start)
+	fired := []int{}
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	clock.AfterFunc(time.Second, func() { fired = append(fired, 1) })
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, 3) })
+	is.True(stopped.Stop())
+	timer := clock.NewTimer(3 * time.Second)
+
+	clock.Advance(time.Second)
+	is.Equal(fired, []int{1})
+	clock.Advance(5 * time.Second)
+	is.Equal(fired, []int{1, 2})
+	is.Equal(<-timer.C(), start.Add(3*time.Second))
+	is.Equal(clock.Now(), start.Add(6*time.Second))
+	is.Equal(clock.PendingTimers(), 0)
+}
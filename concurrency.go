@@ -10,16 +10,42 @@ type ConcurrencyHandler struct {
 	taskCh        chan ConcurrencyTask
 	concurrencyCh chan struct{}
 	wg            *sync.WaitGroup
+	*ConcurrencyOpts
 }
 
-func NewConcurrencyHandler(maxConcurrentTasks int) *ConcurrencyHandler {
+type ConcurrencyOpts struct {
+	Clock Clock
+}
+
+type ConcurrencyOpt func(*ConcurrencyOpts)
+
+func DefaultConcurrencyOpts() *ConcurrencyOpts {
+	return &ConcurrencyOpts{
+		Clock: RealClock(),
+	}
+}
+
+// ClockConcurrencyOpt sets the clock exposed as ConcurrencyHandler.Clock,
+// which tasks can use to wait or measure time.
+func ClockConcurrencyOpt(clock Clock) ConcurrencyOpt {
+	return func(opts *ConcurrencyOpts) {
+		opts.Clock = clock
+	}
+}
+
+func NewConcurrencyHandler(maxConcurrentTasks int, opts ...ConcurrencyOpt) *ConcurrencyHandler {
+	o := DefaultConcurrencyOpts()
+	for _, opt := range opts {
+		opt(o)
+	}
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	return &ConcurrencyHandler{
-		curTaskIdx:    0,
-		taskCh:        make(chan ConcurrencyTask),
-		concurrencyCh: make(chan struct{}, maxConcurrentTasks),
-		wg:            &wg,
+		curTaskIdx:      0,
+		taskCh:          make(chan ConcurrencyTask),
+		concurrencyCh:   make(chan struct{}, maxConcurrentTasks),
+		wg:              &wg,
+		ConcurrencyOpts: o,
 	}
 }
 
@@ -25,3 +25,21 @@ func TestConcurrency(t *testing.T) {
 	connHandler.Done()
 	connHandler.Wait()
 }
+
+func TestConcurrencyClock(t *testing.T) {
+	clock := structures.NewFakeClock(time.Now())
+	connHandler := structures.NewConcurrencyHandler(1, structures.ClockConcurrencyOpt(clock))
+	connHandler.Start()
+	slept := make(chan struct{})
+	connHandler.Enqueue(func() {
+		connHandler.Clock.Sleep(time.Second)
+		close(slept)
+	})
+	for clock.PendingTimers() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+	<-slept
+	connHandler.Done()
+	connHandler.Wait()
+}
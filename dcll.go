@@ -0,0 +1,320 @@
+package structures
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// DoublyCircularLinkedList is a circular list whose Add methods return node
+// handles, like container/list. Handles allow O(1) removal and moves.
+type DoublyCircularLinkedList[T any] struct {
+	tail *DoublyNode[T]
+	size int
+	pool *NodePool[T]
+}
+
+// NewDoublyCircularLinkedList constructs and returns an empty doubly circularly linked-list.
+// time-complexity: O(1)
+func NewDoublyCircularLinkedList[T any]() DoublyCircularLinkedList[T] {
+	return DoublyCircularLinkedList[T]{}
+}
+
+// SetNodePool makes the list take nodes from and return removed nodes to
+// pool. Handles must not be used after their element was removed.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) SetNodePool(pool *NodePool[T]) {
+	c.pool = pool
+}
+
+// IsEmpty returns true if the linked-list doesn't contain any nodes.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) IsEmpty() bool {
+	return c.size == 0
+}
+
+// Len returns the number of nodes in the list.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) Len() int {
+	return c.size
+}
+
+// First returns the first element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) First() (data T, ok bool) {
+	if c.IsEmpty() {
+		return
+	}
+	return c.tail.Next.Data, true
+}
+
+// Last returns the last element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) Last() (data T, ok bool) {
+	if c.IsEmpty() {
+		return
+	}
+	return c.tail.Data, true
+}
+
+// FirstNode returns the handle of the first element or nil if the list is empty.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) FirstNode() *DoublyNode[T] {
+	if c.IsEmpty() {
+		return nil
+	}
+	return c.tail.Next
+}
+
+// LastNode returns the handle of the last element or nil if the list is empty.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) LastNode() *DoublyNode[T] {
+	return c.tail
+}
+
+// Rotate rotates the list. It moves the first element to the end.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) Rotate() {
+	if c.tail != nil {
+		c.tail = c.tail.Next
+	}
+}
+
+// RotateBack rotates the list backwards. It moves the last element to the beginning.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) RotateBack() {
+	if c.tail != nil {
+		c.tail = c.tail.Prev
+	}
+}
+
+// AddFirst adds a new node to the beginning of the list and returns its handle.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) AddFirst(data T) *DoublyNode[T] {
+	n := c.pool.doublyNode(data)
+	c.insertFirst(n)
+	return n
+}
+
+// AddLast adds a new node to the end of the list and returns its handle.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) AddLast(data T) *DoublyNode[T] {
+	n := c.AddFirst(data)
+	c.tail = n
+	return n
+}
+
+// Remove removes the node from the list and returns its data. It returns
+// false if the node doesn't belong to the list.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) Remove(n *DoublyNode[T]) (val T, ok bool) {
+	if n == nil || n.list != c {
+		return
+	}
+	c.unlink(n)
+	val = n.Data
+	c.pool.freeDoublyNode(n)
+	return val, true
+}
+
+// RemoveFirst removes and returns the first element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) RemoveFirst() (val T, ok bool) {
+	return c.Remove(c.FirstNode())
+}
+
+// RemoveLast removes and returns the last element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) RemoveLast() (val T, ok bool) {
+	return c.Remove(c.LastNode())
+}
+
+// MoveToFront moves the node to the beginning of the list.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) MoveToFront(n *DoublyNode[T]) {
+	if n == nil || n.list != c || n == c.tail.Next {
+		return
+	}
+	c.unlink(n)
+	c.insertFirst(n)
+}
+
+// MoveToBack moves the node to the end of the list.
+// time-complexity: O(1)
+func (c *DoublyCircularLinkedList[T]) MoveToBack(n *DoublyNode[T]) {
+	if n == nil || n.list != c || n == c.tail {
+		return
+	}
+	c.unlink(n)
+	c.insertFirst(n)
+	c.tail = n
+}
+
+// Vals returns the elements of the list in order.
+// time-complexity: O(n)
+func (c *DoublyCircularLinkedList[T]) Vals() (vals []T) {
+	if c.IsEmpty() {
+		return
+	}
+
+	for current := c.tail.Next; current != c.tail; current = current.Next {
+		vals = append(vals, current.Data)
+	}
+	vals = append(vals, c.tail.Data)
+	return
+}
+
+// String returns the string representation of the list.
+// time-complexity: O(n)
+func (c *DoublyCircularLinkedList[T]) String() string {
+	if c.IsEmpty() {
+		return "[ ]"
+	}
+
+	var b strings.Builder
+	b.WriteString("[ ")
+
+	for current := c.tail.Next; current != c.tail; current = current.Next {
+		b.WriteString(fmt.Sprint(current.Data))
+		b.WriteString(" ")
+	}
+
+	b.WriteString(fmt.Sprint(c.tail.Data))
+	b.WriteString(" ]")
+
+	return b.String()
+}
+
+// All returns an iterator over the indexes and elements of the list, first
+// to last. The list must not be modified while iterating.
+// time-complexity: O(n)
+func (c *DoublyCircularLinkedList[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		if c.IsEmpty() {
+			return
+		}
+		i := 0
+		for current := c.tail.Next; ; current = current.Next {
+			if !yield(i, current.Data) || current == c.tail {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// Backward returns an iterator over the indexes and elements of the list,
+// last to first.
+// time-complexity: O(n)
+func (c *DoublyCircularLinkedList[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		if c.IsEmpty() {
+			return
+		}
+		i := c.size - 1
+		for current := c.tail; ; current = current.Prev {
+			if !yield(i, current.Data) || current == c.tail.Next {
+				return
+			}
+			i--
+		}
+	}
+}
+
+// Values returns an iterator over the elements of the list, first to last.
+// time-complexity: O(n)
+func (c *DoublyCircularLinkedList[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range c.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON encodes the list as a JSON array, first to last.
+// time-complexity: O(n)
+func (c DoublyCircularLinkedList[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.slice())
+}
+
+// UnmarshalJSON replaces the list with the elements of a JSON array. Handles
+// of the previous elements become invalid.
+// time-complexity: O(n)
+func (c *DoublyCircularLinkedList[T]) UnmarshalJSON(data []byte) error {
+	var vals []T
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	c.reset(vals)
+	return nil
+}
+
+// MarshalBinary encodes the list with encoding/gob.
+// time-complexity: O(n)
+func (c DoublyCircularLinkedList[T]) MarshalBinary() ([]byte, error) {
+	return gobEncode(c.slice())
+}
+
+// UnmarshalBinary replaces the list with gob encoded elements. Handles of
+// the previous elements become invalid.
+// time-complexity: O(n)
+func (c *DoublyCircularLinkedList[T]) UnmarshalBinary(data []byte) error {
+	var vals []T
+	if err := gobDecode(data, &vals); err != nil {
+		return err
+	}
+	c.reset(vals)
+	return nil
+}
+
+func (c *DoublyCircularLinkedList[T]) slice() []T {
+	vals := c.Vals()
+	if vals == nil {
+		vals = []T{}
+	}
+	return vals
+}
+
+func (c *DoublyCircularLinkedList[T]) reset(vals []T) {
+	for !c.IsEmpty() {
+		c.RemoveFirst()
+	}
+	for _, v := range vals {
+		c.AddLast(v)
+	}
+}
+
+func (c *DoublyCircularLinkedList[T]) insertFirst(n *DoublyNode[T]) {
+	n.list = c
+	if c.IsEmpty() {
+		n.Next = n
+		n.Prev = n
+		c.tail = n
+	} else {
+		head := c.tail.Next
+		n.Next = head
+		n.Prev = c.tail
+		head.Prev = n
+		c.tail.Next = n
+	}
+	c.size++
+}
+
+func (c *DoublyCircularLinkedList[T]) unlink(n *DoublyNode[T]) {
+	if n.Next == n {
+		c.tail = nil
+	} else {
+		n.Prev.Next = n.Next
+		n.Next.Prev = n.Prev
+		if n == c.tail {
+			c.tail = n.Prev
+		}
+	}
+	n.Next = nil
+	n.Prev = nil
+	n.list = nil
+	c.size--
+}
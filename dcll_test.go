@@ -0,0 +1,77 @@
+package structures_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestDoublyCircularLinkedListHandles(t *testing.T) {
+	is := is.New(t)
+	list := structures.NewDoublyCircularLinkedList[int]()
+	one := list.AddLast(1)
+	two := list.AddLast(2)
+	list.AddLast(3)
+	list.AddFirst(0)
+	is.Equal(list.Vals(), []int{0, 1, 2, 3})
+
+	val, ok := list.Remove(two)
+	is.True(ok)
+	is.Equal(val, 2)
+	_, ok = list.Remove(two)
+	is.True(!ok) // already removed
+
+	list.MoveToBack(one)
+	is.Equal(list.Vals(), []int{0, 3, 1})
+	list.MoveToFront(one)
+	is.Equal(list.Vals(), []int{1, 0, 3})
+
+	list.Rotate()
+	is.Equal(list.Vals(), []int{0, 3, 1})
+	list.RotateBack()
+	is.Equal(list.Vals(), []int{1, 0, 3})
+
+	last, ok := list.RemoveLast()
+	is.True(ok)
+	is.Equal(last, 3)
+	first, ok := list.RemoveFirst()
+	is.True(ok)
+	is.Equal(first, 1)
+	is.Equal(list.Len(), 1)
+	is.Equal(list.String(), "[ 0 ]")
+}
+
+func BenchmarkBalancerRemove(b *testing.B) {
+	const size = 50_000
+	vals := make([]int, size)
+	for i := range vals {
+		vals[i] = i
+	}
+	balancer := structures.NewBalancer[int]()
+	balancer.Add(vals...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v := i % size
+		balancer.Remove(v)
+		balancer.AddLast(v)
+	}
+}
+
+func TestDoublyCircularLinkedListIterators(t *testing.T) {
+	is := is.New(t)
+	list := structures.NewDoublyCircularLinkedList[string]()
+	list.AddLast("a")
+	list.AddLast("b")
+	list.AddLast("c")
+	is.Equal(slices.Collect(list.Values()), []string{"a", "b", "c"})
+	backward := []string{}
+	for i, v := range list.Backward() {
+		if i == 0 {
+			break
+		}
+		backward = append(backward, v)
+	}
+	is.Equal(backward, []string{"c", "b"})
+}
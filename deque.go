@@ -0,0 +1,307 @@
+package structures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Deque is a double-ended queue safe for concurrent use. Elements live in a
+// growable ring so pushes and pops don't allocate once it has grown, and the
+// lock is only held for a few instructions. With a capacity, pushes reject
+// or wait when the deque is full.
+type Deque[T any] struct {
+	buf      []T
+	head     int
+	size     int
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	*DequeOpts
+}
+
+type DequeOpts struct {
+	Capacity int
+}
+
+type DequeOpt func(*DequeOpts)
+
+func DefaultDequeOpts() *DequeOpts {
+	return &DequeOpts{
+		Capacity: -1,
+	}
+}
+
+// CapacityDequeOpt bounds the deque to capacity elements.
+func CapacityDequeOpt(capacity int) DequeOpt {
+	return func(opts *DequeOpts) {
+		opts.Capacity = capacity
+	}
+}
+
+func NewDeque[T any](opts ...DequeOpt) *Deque[T] {
+	o := DefaultDequeOpts()
+	for _, opt := range opts {
+		opt(o)
+	}
+	d := &Deque[T]{
+		buf:       make([]T, 16),
+		DequeOpts: o,
+	}
+	if o.Capacity >= 0 && o.Capacity < len(d.buf) {
+		d.buf = make([]T, max(o.Capacity, 1))
+	}
+	d.notEmpty = sync.NewCond(&d.mu)
+	d.notFull = sync.NewCond(&d.mu)
+	return d
+}
+
+// PushFront adds an element to the front. It returns false if the deque is full.
+// time-complexity: O(1) amortized
+func (d *Deque[T]) PushFront(val T) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.isFull() {
+		return false
+	}
+	d.pushFront(val)
+	return true
+}
+
+// PushBack adds an element to the back. It returns false if the deque is full.
+// time-complexity: O(1) amortized
+func (d *Deque[T]) PushBack(val T) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.isFull() {
+		return false
+	}
+	d.pushBack(val)
+	return true
+}
+
+// PushFrontWait adds an element to the front, waiting for room until ctx is done.
+func (d *Deque[T]) PushFrontWait(ctx context.Context, val T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := waitCond(ctx, &d.mu, d.notFull, d.isFull); err != nil {
+		return err
+	}
+	d.pushFront(val)
+	return nil
+}
+
+// PushBackWait adds an element to the back, waiting for room until ctx is done.
+func (d *Deque[T]) PushBackWait(ctx context.Context, val T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := waitCond(ctx, &d.mu, d.notFull, d.isFull); err != nil {
+		return err
+	}
+	d.pushBack(val)
+	return nil
+}
+
+// PopFront removes and returns the first element. It returns false if the deque is empty.
+// time-complexity: O(1)
+func (d *Deque[T]) PopFront() (val T, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.size == 0 {
+		return
+	}
+	return d.popFront(), true
+}
+
+// PopBack removes and returns the last element. It returns false if the deque is empty.
+// time-complexity: O(1)
+func (d *Deque[T]) PopBack() (val T, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.size == 0 {
+		return
+	}
+	return d.popBack(), true
+}
+
+// PopFrontWait removes and returns the first element, waiting for one until ctx is done.
+func (d *Deque[T]) PopFrontWait(ctx context.Context) (val T, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err = waitCond(ctx, &d.mu, d.notEmpty, d.isEmpty); err != nil {
+		return val, err
+	}
+	return d.popFront(), nil
+}
+
+// PopBackWait removes and returns the last element, waiting for one until ctx is done.
+func (d *Deque[T]) PopBackWait(ctx context.Context) (val T, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err = waitCond(ctx, &d.mu, d.notEmpty, d.isEmpty); err != nil {
+		return val, err
+	}
+	return d.popBack(), nil
+}
+
+// PeekFront returns the first element. It returns false if the deque is empty.
+func (d *Deque[T]) PeekFront() (val T, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.size == 0 {
+		return
+	}
+	return d.buf[d.head], true
+}
+
+// PeekBack returns the last element. It returns false if the deque is empty.
+func (d *Deque[T]) PeekBack() (val T, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.size == 0 {
+		return
+	}
+	return d.buf[d.index(d.size-1)], true
+}
+
+func (d *Deque[T]) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.size
+}
+
+// Vals returns a copy of the elements front to back.
+func (d *Deque[T]) Vals() []T {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	vals := make([]T, d.size)
+	for i := range vals {
+		vals[i] = d.buf[d.index(i)]
+	}
+	return vals
+}
+
+func (d *Deque[T]) Clear() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	clear(d.buf)
+	d.head = 0
+	d.size = 0
+	d.notFull.Broadcast()
+}
+
+// MarshalJSON encodes the deque as a JSON array, front to back. The
+// capacity isn't encoded.
+// time-complexity: O(n)
+func (d *Deque[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Vals())
+}
+
+// UnmarshalJSON replaces the deque contents with the elements of a JSON
+// array. It fails if they exceed the capacity.
+// time-complexity: O(n)
+func (d *Deque[T]) UnmarshalJSON(data []byte) error {
+	var vals []T
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	return d.reset(vals)
+}
+
+// MarshalBinary encodes the deque with encoding/gob, front to back.
+// time-complexity: O(n)
+func (d *Deque[T]) MarshalBinary() ([]byte, error) {
+	return gobEncode(d.Vals())
+}
+
+// UnmarshalBinary replaces the deque contents with gob encoded elements.
+// time-complexity: O(n)
+func (d *Deque[T]) UnmarshalBinary(data []byte) error {
+	var vals []T
+	if err := gobDecode(data, &vals); err != nil {
+		return err
+	}
+	return d.reset(vals)
+}
+
+// reset replaces the contents with vals, setting up a zero value deque
+// without a capacity first.
+func (d *Deque[T]) reset(vals []T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.DequeOpts == nil {
+		d.DequeOpts = DefaultDequeOpts()
+		d.notEmpty = sync.NewCond(&d.mu)
+		d.notFull = sync.NewCond(&d.mu)
+	}
+	if d.Capacity >= 0 && len(vals) > d.Capacity {
+		return fmt.Errorf("deque holds at most %d elements, got %d", d.Capacity, len(vals))
+	}
+	d.buf = make([]T, max(len(vals), 1))
+	copy(d.buf, vals)
+	d.head = 0
+	d.size = len(vals)
+	d.notEmpty.Broadcast()
+	d.notFull.Broadcast()
+	return nil
+}
+
+func (d *Deque[T]) isEmpty() bool {
+	return d.size == 0
+}
+
+func (d *Deque[T]) isFull() bool {
+	return d.Capacity >= 0 && d.size >= d.Capacity
+}
+
+func (d *Deque[T]) pushFront(val T) {
+	d.grow()
+	d.head = d.index(len(d.buf) - 1)
+	d.buf[d.head] = val
+	d.size++
+	d.notEmpty.Signal()
+}
+
+func (d *Deque[T]) pushBack(val T) {
+	d.grow()
+	d.buf[d.index(d.size)] = val
+	d.size++
+	d.notEmpty.Signal()
+}
+
+func (d *Deque[T]) popFront() T {
+	var zero T
+	val := d.buf[d.head]
+	d.buf[d.head] = zero
+	d.head = d.index(1)
+	d.size--
+	d.notFull.Signal()
+	return val
+}
+
+func (d *Deque[T]) popBack() T {
+	var zero T
+	i := d.index(d.size - 1)
+	val := d.buf[i]
+	d.buf[i] = zero
+	d.size--
+	d.notFull.Signal()
+	return val
+}
+
+// grow doubles the ring when it is full, unwrapping it to start at 0.
+func (d *Deque[T]) grow() {
+	if d.size < len(d.buf) {
+		return
+	}
+	buf := make([]T, len(d.buf)*2)
+	n := copy(buf, d.buf[d.head:])
+	copy(buf[n:], d.buf[:d.head])
+	d.buf = buf
+	d.head = 0
+}
+
+func (d *Deque[T]) index(offset int) int {
+	return (d.head + offset) % len(d.buf)
+}
@@ -0,0 +1,115 @@
+package structures_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestDeque(t *testing.T) {
+	is := is.New(t)
+	deque := structures.NewDeque[int]()
+	for i := 0; i < 40; i++ {
+		deque.PushBack(i)
+		deque.PushFront(-i)
+	}
+	is.Equal(deque.Len(), 80)
+	front, _ := deque.PeekFront()
+	back, _ := deque.PeekBack()
+	is.Equal(front, -39)
+	is.Equal(back, 39)
+	v, ok := deque.PopFront()
+	is.True(ok)
+	is.Equal(v, -39)
+	v, ok = deque.PopBack()
+	is.True(ok)
+	is.Equal(v, 39)
+	deque.Clear()
+	_, ok = deque.PopBack()
+	is.True(!ok)
+}
+
+func TestDequeCapacity(t *testing.T) {
+	is := is.New(t)
+	deque := structures.NewDeque[int](structures.CapacityDequeOpt(2))
+	is.True(deque.PushBack(1))
+	is.True(deque.PushFront(0))
+	is.True(!deque.PushBack(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	is.True(errors.Is(deque.PushBackWait(ctx, 2), context.DeadlineExceeded))
+
+	pushed := make(chan struct{})
+	go func() {
+		is.NoErr(deque.PushBackWait(context.Background(), 2))
+		close(pushed)
+	}()
+	v, err := deque.PopFrontWait(context.Background())
+	is.NoErr(err)
+	is.Equal(v, 0)
+	<-pushed
+	is.Equal(deque.Vals(), []int{1, 2})
+}
+
+func TestDequePopWait(t *testing.T) {
+	is := is.New(t)
+	deque := structures.NewDeque[string]()
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		deque.PushFront("a")
+	}()
+	v, err := deque.PopBackWait(context.Background())
+	is.NoErr(err)
+	is.Equal(v, "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = deque.PopFrontWait(ctx)
+	is.Equal(err, context.Canceled)
+}
+
+// lockedCLL is how a queue had to be built before Deque existed.
+type lockedCLL struct {
+	list structures.CircularLinkedList[int]
+	mu   sync.Mutex
+}
+
+func (l *lockedCLL) PushBack(v int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.list.AddLast(v)
+}
+
+func (l *lockedCLL) PopFront() (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.list.RemoveFirst()
+}
+
+func BenchmarkDequeParallel(b *testing.B) {
+	deque := structures.NewDeque[int]()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			deque.PushBack(i)
+			deque.PopFront()
+		}
+	})
+}
+
+func BenchmarkLockedCircularLinkedListParallel(b *testing.B) {
+	list := &lockedCLL{}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			list.PushBack(i)
+			list.PopFront()
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package structures
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+)
+
+// cacheEntry is the encoded form of a Cache key.
+type cacheEntry[K comparable] struct {
+	Key K             `json:"key"`
+	TTL time.Duration `json:"ttl"`
+}
+
+// cacheMapEntry is the encoded form of a CacheMap entry. TTL is the time
+// left until the entry expires, so it survives a round trip unchanged.
+type cacheMapEntry[K comparable, V any] struct {
+	Key   K             `json:"key"`
+	Value V             `json:"value"`
+	TTL   time.Duration `json:"ttl"`
+}
+
+func gobEncode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gobDecode(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,242 @@
+package structures_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestCircularLinkedListEncoding(t *testing.T) {
+	is := is.New(t)
+	list := newTestCLL(1, 2, 3)
+	data, err := json.Marshal(list)
+	is.NoErr(err)
+	is.Equal(string(data), "[1,2,3]")
+
+	var decoded structures.CircularLinkedList[int]
+	is.NoErr(json.Unmarshal(data, &decoded))
+	is.Equal(decoded.Vals(), []int{1, 2, 3})
+
+	var buf bytes.Buffer
+	is.NoErr(gob.NewEncoder(&buf).Encode(list))
+	var gobDecoded structures.CircularLinkedList[int]
+	is.NoErr(gob.NewDecoder(&buf).Decode(&gobDecoded))
+	is.Equal(gobDecoded.Vals(), []int{1, 2, 3})
+
+	empty := structures.NewCircularLinkedList[int]()
+	data, err = json.Marshal(empty)
+	is.NoErr(err)
+	is.Equal(string(data), "[]")
+}
+
+func TestDoublyCircularLinkedListEncoding(t *testing.T) {
+	is := is.New(t)
+	list := structures.NewDoublyCircularLinkedList[int]()
+	list.AddLast(1)
+	list.AddLast(2)
+	// A list held by value still encodes as an array, also nested
+	data, err := json.Marshal(map[string]any{"x": list})
+	is.NoErr(err)
+	is.Equal(string(data), `{"x":[1,2]}`)
+
+	var decoded structures.DoublyCircularLinkedList[int]
+	is.NoErr(json.Unmarshal([]byte("[1,2]"), &decoded))
+	is.Equal(decoded.Vals(), []int{1, 2})
+
+	var buf bytes.Buffer
+	is.NoErr(gob.NewEncoder(&buf).Encode(list))
+	var gobDecoded structures.DoublyCircularLinkedList[int]
+	is.NoErr(gob.NewDecoder(&buf).Decode(&gobDecoded))
+	is.Equal(gobDecoded.Vals(), []int{1, 2})
+}
+
+func TestSetEncoding(t *testing.T) {
+	is := is.New(t)
+	data, err := json.Marshal(structures.NewSet("a"))
+	is.NoErr(err)
+	is.Equal(string(data), `["a"]`)
+
+	set := structures.NewSet[string]()
+	is.NoErr(json.Unmarshal([]byte(`["a","b","a"]`), set))
+	is.Equal(slices.Sorted(set.All()), []string{"a", "b"})
+
+	data, err = set.MarshalBinary()
+	is.NoErr(err)
+	decoded := &structures.Set[string]{}
+	is.NoErr(decoded.UnmarshalBinary(data))
+	is.Equal(decoded.Size(), 2)
+}
+
+func TestSafeMapEncoding(t *testing.T) {
+	is := is.New(t)
+	safeMap := structures.NewSafeMap(map[string]int{"a": 1})
+	data, err := json.Marshal(safeMap)
+	is.NoErr(err)
+	is.Equal(string(data), `{"a":1}`)
+
+	decoded := structures.NewSafeMap[string, int]()
+	is.NoErr(json.Unmarshal(data, decoded))
+	is.Equal(decoded.MustGet("a"), 1)
+
+	data, err = safeMap.MarshalBinary()
+	is.NoErr(err)
+	decoded = structures.NewSafeMap[string, int]()
+	is.NoErr(decoded.UnmarshalBinary(data))
+	is.Equal(decoded.MustGet("a"), 1)
+}
+
+func TestCacheMapEncodingKeepsTTL(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	cache := structures.NewCacheMap[string, int](time.Minute, structures.ClockCacheOpt(clock))
+	cache.Add("a", 1)
+	cache.AddWithExpiry("b", 2, time.Second)
+	clock.Advance(500 * time.Millisecond)
+
+	data, err := json.Marshal(cache)
+	is.NoErr(err)
+	decoded := structures.NewCacheMap[string, int](time.Minute,
+		structures.AutoDeleteCacheOpt(),
+		structures.ClockCacheOpt(clock),
+	)
+	is.NoErr(json.Unmarshal(data, decoded))
+	is.Equal(decoded.Len(), 2)
+	clock.Advance(500 * time.Millisecond)
+	is.Equal(decoded.Len(), 1) // b kept its remaining 500ms
+	v, _ := decoded.Get("a")
+	is.Equal(v, 1)
+
+	data, err = cache.MarshalBinary()
+	is.NoErr(err)
+	var gobDecoded structures.CacheMap[string, int]
+	is.NoErr(gobDecoded.UnmarshalBinary(data))
+	is.Equal(gobDecoded.Len(), 1) // b expired on the fake clock before encoding
+}
+
+func TestCacheEncoding(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewCache[int](time.Minute)
+	cache.Add(1, 2)
+	data, err := json.Marshal(cache)
+	is.NoErr(err)
+	var decoded structures.Cache[int]
+	is.NoErr(json.Unmarshal(data, &decoded))
+	is.True(decoded.Contains(1))
+	is.True(decoded.Contains(2))
+}
+
+func TestCacheEncodingZeroValuePersisted(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewCache[string](time.Minute)
+	cache.Add("a")
+	cache.Persist("a")
+	data, err := json.Marshal(cache)
+	is.NoErr(err)
+	var decoded structures.Cache[string]
+	is.NoErr(json.Unmarshal(data, &decoded))
+	ttl, ok := decoded.TTL("a")
+	is.True(ok)
+	is.Equal(ttl, structures.NoTTL)
+
+	cacheMap := structures.NewCacheMap[string, int](time.Minute)
+	cacheMap.Add("a", 1)
+	cacheMap.Persist("a")
+	data, err = cacheMap.MarshalBinary()
+	is.NoErr(err)
+	var decodedMap structures.CacheMap[string, int]
+	is.NoErr(decodedMap.UnmarshalBinary(data))
+	v, ok := decodedMap.Get("a")
+	is.True(ok)
+	is.Equal(v, 1)
+	ttl, _ = decodedMap.TTL("a")
+	is.Equal(ttl, structures.NoTTL)
+
+	data, err = json.Marshal(cacheMap)
+	is.NoErr(err)
+	var decodedSharded structures.ShardedCacheMap[string, int]
+	is.NoErr(json.Unmarshal(data, &decodedSharded))
+	v, ok = decodedSharded.Get("a")
+	is.True(ok)
+	is.Equal(v, 1)
+	ttl, _ = decodedSharded.TTL("a")
+	is.Equal(ttl, structures.NoTTL)
+}
+
+func TestLinkedListEncoding(t *testing.T) {
+	is := is.New(t)
+	list := structures.NewLinkedList(1, 2, 3)
+	data, err := json.Marshal(map[string]any{"x": *list})
+	is.NoErr(err)
+	is.Equal(string(data), `{"x":[1,2,3]}`)
+
+	var decoded structures.LinkedList[int]
+	is.NoErr(json.Unmarshal([]byte("[1,2,3]"), &decoded))
+	is.Equal(decoded.Vals(), []int{1, 2, 3})
+
+	doubly := structures.NewDoublyLinkedList(1, 2)
+	data, err = doubly.MarshalBinary()
+	is.NoErr(err)
+	var gobDecoded structures.DoublyLinkedList[int]
+	is.NoErr(gobDecoded.UnmarshalBinary(data))
+	is.Equal(gobDecoded.Vals(), []int{1, 2})
+	last, _ := gobDecoded.Last()
+	is.Equal(last, 2)
+}
+
+func TestRingBufferEncoding(t *testing.T) {
+	is := is.New(t)
+	rb := structures.NewRingBuffer[int](3)
+	for i := range 5 {
+		rb.Push(i)
+	}
+	data, err := json.Marshal(rb)
+	is.NoErr(err)
+	is.Equal(string(data), "[2,3,4]")
+
+	var decoded structures.RingBuffer[int]
+	is.NoErr(json.Unmarshal(data, &decoded))
+	is.Equal(decoded.Vals(), []int{2, 3, 4})
+	is.Equal(decoded.Cap(), 3)
+
+	// Elements that don't fit are dropped oldest first, or rejected
+	small := structures.NewRingBuffer[int](2)
+	is.NoErr(json.Unmarshal(data, small))
+	is.Equal(small.Vals(), []int{3, 4})
+	reject := structures.NewSafeRingBuffer[int](2, structures.RejectRingBufferOpt())
+	is.True(json.Unmarshal(data, reject) != nil)
+
+	data, err = reject.MarshalBinary()
+	is.NoErr(err)
+	var safe structures.SafeRingBuffer[int]
+	is.NoErr(safe.UnmarshalBinary(data))
+	is.Equal(safe.Len(), 0)
+	is.True(safe.Push(1))
+	is.Equal(safe.Vals(), []int{1})
+}
+
+func TestDequeEncoding(t *testing.T) {
+	is := is.New(t)
+	deque := structures.NewDeque[int]()
+	deque.PushBack(2)
+	deque.PushFront(1)
+	data, err := json.Marshal(deque)
+	is.NoErr(err)
+	is.Equal(string(data), "[1,2]")
+
+	var decoded structures.Deque[int]
+	is.NoErr(json.Unmarshal(data, &decoded))
+	is.Equal(decoded.Vals(), []int{1, 2})
+	is.True(decoded.PushBack(3))
+	is.Equal(decoded.Vals(), []int{1, 2, 3})
+
+	bounded := structures.NewDeque[int](structures.CapacityDequeOpt(1))
+	data, err = decoded.MarshalBinary()
+	is.NoErr(err)
+	is.True(bounded.UnmarshalBinary(data) != nil)
+}
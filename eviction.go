@@ -0,0 +1,633 @@
+package structures
+
+import (
+	"hash/maphash"
+)
+
+// EvictionPolicy decides which entry a capacity bounded CacheMap evicts.
+// The cache calls it with its own lock held, so implementations don't need
+// to be safe for concurrent use and must not call back into the cache.
+type EvictionPolicy[K comparable] interface {
+	// Add is called when a new key is stored.
+	Add(key K)
+	// Access is called when a stored key is read with Get or replaced.
+	Access(key K)
+	// Remove is called when a key leaves the cache other than through Evict.
+	Remove(key K)
+	// Evict forgets and returns the key to evict. It may return the key
+	// that was just added, which rejects it. It returns false if no key is
+	// tracked.
+	Evict() (key K, ok bool)
+	// Clear forgets every key.
+	Clear()
+}
+
+// EvictionPeeker is implemented by policies that can return the key Evict
+// would evict without evicting it. ShardedCacheMap uses it to compare the
+// candidates of its shards; all built-in policies implement it.
+type EvictionPeeker[K comparable] interface {
+	Peek() (key K, ok bool)
+}
+
+// EvictionKind selects one of the built-in eviction policies.
+type EvictionKind int
+
+const (
+	// EvictionLRU evicts the least recently used entry.
+	EvictionLRU EvictionKind = iota
+	// EvictionLFU evicts the least frequently used entry, the least recently
+	// used one among ties.
+	EvictionLFU
+	// EvictionARC balances recency and frequency adaptively (Adaptive
+	// Replacement Cache), which keeps one-off scans from flushing hot keys.
+	EvictionARC
+	// EvictionTinyLFU only admits a new entry if it is estimated to be used
+	// more often than the entry it would replace (W-TinyLFU).
+	EvictionTinyLFU
+)
+
+// EvictReason tells an OnEvict func why an entry left the cache.
+type EvictReason int
+
+const (
+	// EvictExpired means the entry's TTL passed.
+	EvictExpired EvictReason = iota
+	// EvictEvicted means the entry was evicted to stay within capacity.
+	EvictEvicted
+	// EvictDeleted means the entry was removed with Delete.
+	EvictDeleted
+	// EvictReplaced means Add stored a new value for the key.
+	EvictReplaced
+	// EvictCleared means the entry was removed with Clear.
+	EvictCleared
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictEvicted:
+		return "evicted"
+	case EvictDeleted:
+		return "deleted"
+	case EvictReplaced:
+		return "replaced"
+	case EvictCleared:
+		return "cleared"
+	default:
+		return "unknown"
+	}
+}
+
+// evictEvent is an OnEvict call queued until the cache lock is released.
+type evictEvent[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// newEvictionPolicy returns the built-in policy for kind sized for capacity entries.
+func newEvictionPolicy[K comparable](kind EvictionKind, capacity int) EvictionPolicy[K] {
+	switch kind {
+	case EvictionLFU:
+		return NewLFUPolicy[K]()
+	case EvictionARC:
+		return NewARCPolicy[K](capacity)
+	case EvictionTinyLFU:
+		return NewTinyLFUPolicy[K](capacity)
+	default:
+		return NewLRUPolicy[K]()
+	}
+}
+
+// keyList is a recency ordered list of keys, most recent first, with O(1)
+// access to any key's node.
+type keyList[K comparable] struct {
+	list  DoublyCircularLinkedList[K]
+	nodes map[K]*DoublyNode[K]
+}
+
+func newKeyList[K comparable]() keyList[K] {
+	return keyList[K]{
+		list:  NewDoublyCircularLinkedList[K](),
+		nodes: map[K]*DoublyNode[K]{},
+	}
+}
+
+func (l *keyList[K]) len() int {
+	return l.list.Len()
+}
+
+func (l *keyList[K]) has(key K) bool {
+	_, ok := l.nodes[key]
+	return ok
+}
+
+// push adds key as the most recent, or moves it there.
+func (l *keyList[K]) push(key K) {
+	if n, ok := l.nodes[key]; ok {
+		l.list.MoveToFront(n)
+		return
+	}
+	l.nodes[key] = l.list.AddFirst(key)
+}
+
+func (l *keyList[K]) remove(key K) bool {
+	n, ok := l.nodes[key]
+	if !ok {
+		return false
+	}
+	l.list.Remove(n)
+	delete(l.nodes, key)
+	return true
+}
+
+// oldest returns the least recent key without removing it.
+func (l *keyList[K]) oldest() (key K, ok bool) {
+	return l.list.Last()
+}
+
+// pop removes and returns the least recent key.
+func (l *keyList[K]) pop() (key K, ok bool) {
+	key, ok = l.list.RemoveLast()
+	if ok {
+		delete(l.nodes, key)
+	}
+	return key, ok
+}
+
+func (l *keyList[K]) clear() {
+	l.list = NewDoublyCircularLinkedList[K]()
+	clear(l.nodes)
+}
+
+// LRUPolicy evicts the least recently used key. Every operation is O(1).
+type LRUPolicy[K comparable] struct {
+	keys keyList[K]
+}
+
+func NewLRUPolicy[K comparable]() *LRUPolicy[K] {
+	return &LRUPolicy[K]{keys: newKeyList[K]()}
+}
+
+// time-complexity: O(1)
+func (p *LRUPolicy[K]) Add(key K) {
+	p.keys.push(key)
+}
+
+// time-complexity: O(1)
+func (p *LRUPolicy[K]) Access(key K) {
+	if p.keys.has(key) {
+		p.keys.push(key)
+	}
+}
+
+// time-complexity: O(1)
+func (p *LRUPolicy[K]) Remove(key K) {
+	p.keys.remove(key)
+}
+
+// time-complexity: O(1)
+func (p *LRUPolicy[K]) Evict() (key K, ok bool) {
+	return p.keys.pop()
+}
+
+// time-complexity: O(1)
+func (p *LRUPolicy[K]) Peek() (key K, ok bool) {
+	return p.keys.oldest()
+}
+
+// time-complexity: O(1)
+func (p *LRUPolicy[K]) Clear() {
+	p.keys.clear()
+}
+
+// LFUPolicy evicts the least frequently used key, and the least recently
+// used among keys with the same count. Keys are grouped in buckets per use
+// count so every operation is O(1).
+type LFUPolicy[K comparable] struct {
+	head    *lfuBucket[K]
+	buckets map[K]*lfuBucket[K]
+}
+
+// lfuBucket holds the keys used exactly freq times. Buckets form a list
+// ordered by freq, starting at LFUPolicy.head.
+type lfuBucket[K comparable] struct {
+	freq       int
+	keys       keyList[K]
+	prev, next *lfuBucket[K]
+}
+
+func NewLFUPolicy[K comparable]() *LFUPolicy[K] {
+	return &LFUPolicy[K]{buckets: map[K]*lfuBucket[K]{}}
+}
+
+// time-complexity: O(1)
+func (p *LFUPolicy[K]) Add(key K) {
+	if _, ok := p.buckets[key]; ok {
+		p.Access(key)
+		return
+	}
+	if p.head == nil || p.head.freq != 1 {
+		b := &lfuBucket[K]{freq: 1, keys: newKeyList[K](), next: p.head}
+		if p.head != nil {
+			p.head.prev = b
+		}
+		p.head = b
+	}
+	p.head.keys.push(key)
+	p.buckets[key] = p.head
+}
+
+// time-complexity: O(1)
+func (p *LFUPolicy[K]) Access(key K) {
+	b, ok := p.buckets[key]
+	if !ok {
+		return
+	}
+	next := b.next
+	if next == nil || next.freq != b.freq+1 {
+		next = &lfuBucket[K]{freq: b.freq + 1, keys: newKeyList[K](), prev: b, next: b.next}
+		if b.next != nil {
+			b.next.prev = next
+		}
+		b.next = next
+	}
+	b.keys.remove(key)
+	next.keys.push(key)
+	p.buckets[key] = next
+	p.dropIfEmpty(b)
+}
+
+// time-complexity: O(1)
+func (p *LFUPolicy[K]) Remove(key K) {
+	b, ok := p.buckets[key]
+	if !ok {
+		return
+	}
+	b.keys.remove(key)
+	delete(p.buckets, key)
+	p.dropIfEmpty(b)
+}
+
+// time-complexity: O(1)
+func (p *LFUPolicy[K]) Evict() (key K, ok bool) {
+	if p.head == nil {
+		return key, false
+	}
+	b := p.head
+	key, ok = b.keys.pop()
+	delete(p.buckets, key)
+	p.dropIfEmpty(b)
+	return key, ok
+}
+
+// time-complexity: O(1)
+func (p *LFUPolicy[K]) Peek() (key K, ok bool) {
+	if p.head == nil {
+		return key, false
+	}
+	return p.head.keys.oldest()
+}
+
+// time-complexity: O(1)
+func (p *LFUPolicy[K]) Clear() {
+	p.head = nil
+	clear(p.buckets)
+}
+
+func (p *LFUPolicy[K]) dropIfEmpty(b *lfuBucket[K]) {
+	if b.keys.len() > 0 {
+		return
+	}
+	if b.prev != nil {
+		b.prev.next = b.next
+	} else {
+		p.head = b.next
+	}
+	if b.next != nil {
+		b.next.prev = b.prev
+	}
+}
+
+// ARCPolicy implements the Adaptive Replacement Cache. Keys seen once live
+// in t1 and keys seen again in t2. The ghost lists b1 and b2 remember
+// recently evicted keys, and hits on them shift the target size p of t1
+// towards whichever list would have kept the key. Every operation is O(1).
+type ARCPolicy[K comparable] struct {
+	capacity int
+	p        int
+	t1, t2   keyList[K]
+	b1, b2   keyList[K]
+	added    K
+	hasAdded bool
+	ghostB2  bool
+}
+
+// NewARCPolicy returns an ARCPolicy for a cache of capacity entries.
+func NewARCPolicy[K comparable](capacity int) *ARCPolicy[K] {
+	return &ARCPolicy[K]{
+		capacity: max(capacity, 1),
+		t1:       newKeyList[K](),
+		t2:       newKeyList[K](),
+		b1:       newKeyList[K](),
+		b2:       newKeyList[K](),
+	}
+}
+
+// time-complexity: O(1)
+func (p *ARCPolicy[K]) Add(key K) {
+	p.added, p.hasAdded, p.ghostB2 = key, true, false
+	switch {
+	case p.t1.has(key) || p.t2.has(key):
+		p.Access(key)
+	case p.b1.has(key):
+		p.p = min(p.capacity, p.p+max(p.b2.len()/p.b1.len(), 1))
+		p.b1.remove(key)
+		p.t2.push(key)
+	case p.b2.has(key):
+		p.p = max(0, p.p-max(p.b1.len()/p.b2.len(), 1))
+		p.b2.remove(key)
+		p.t2.push(key)
+		p.ghostB2 = true
+	default:
+		p.t1.push(key)
+	}
+}
+
+// time-complexity: O(1)
+func (p *ARCPolicy[K]) Access(key K) {
+	if p.t1.remove(key) || p.t2.has(key) {
+		p.t2.push(key)
+	}
+}
+
+// time-complexity: O(1)
+func (p *ARCPolicy[K]) Remove(key K) {
+	if !p.t1.remove(key) {
+		p.t2.remove(key)
+	}
+}
+
+// Evict runs ARC's REPLACE step. The key just added doesn't count towards
+// t1's size, since ARC replaces before inserting.
+// time-complexity: O(1)
+func (p *ARCPolicy[K]) Evict() (key K, ok bool) {
+	if p.replaceT1() {
+		key, ok = p.t1.pop()
+		if ok {
+			p.b1.push(key)
+		}
+	}
+	if !ok {
+		key, ok = p.t2.pop()
+		if ok {
+			p.b2.push(key)
+		}
+	}
+	if ok && key == p.added {
+		p.hasAdded = false
+	}
+	for p.t1.len()+p.b1.len() > p.capacity && p.b1.len() > 0 {
+		p.b1.pop()
+	}
+	for p.t1.len()+p.t2.len()+p.b1.len()+p.b2.len() > 2*p.capacity {
+		if _, dropped := p.b2.pop(); !dropped {
+			p.b1.pop()
+		}
+	}
+	return key, ok
+}
+
+// time-complexity: O(1)
+func (p *ARCPolicy[K]) Peek() (key K, ok bool) {
+	if p.replaceT1() {
+		if key, ok = p.t1.oldest(); ok {
+			return key, true
+		}
+	}
+	return p.t2.oldest()
+}
+
+// replaceT1 reports whether REPLACE takes its victim from t1.
+func (p *ARCPolicy[K]) replaceT1() bool {
+	t1 := p.t1.len()
+	if p.hasAdded && p.t1.has(p.added) {
+		t1--
+	}
+	return p.t2.len() == 0 || t1 > 0 && (t1 > p.p || p.ghostB2 && t1 == p.p)
+}
+
+// time-complexity: O(1)
+func (p *ARCPolicy[K]) Clear() {
+	p.p = 0
+	p.hasAdded = false
+	p.t1.clear()
+	p.t2.clear()
+	p.b1.clear()
+	p.b2.clear()
+}
+
+// TinyLFUPolicy implements W-TinyLFU. New keys enter a small LRU window.
+// When the cache is full, the oldest window key is only admitted to the
+// main segmented LRU if a count-min sketch estimates it is used more often
+// than the main segment's eviction candidate, so one-off keys can't push
+// out popular ones. The main segment keeps keys hit while on probation in
+// a protected LRU. Every operation is O(1).
+type TinyLFUPolicy[K comparable] struct {
+	window       keyList[K]
+	probation    keyList[K]
+	protected    keyList[K]
+	windowCap    int
+	mainCap      int
+	protectedCap int
+	sketch       *countMinSketch[K]
+}
+
+// NewTinyLFUPolicy returns a TinyLFUPolicy for a cache of capacity entries.
+// 1% of the capacity goes to the window and 80% of the rest to the
+// protected segment.
+func NewTinyLFUPolicy[K comparable](capacity int) *TinyLFUPolicy[K] {
+	capacity = max(capacity, 1)
+	windowCap := max(capacity/100, 1)
+	mainCap := max(capacity-windowCap, 1)
+	return &TinyLFUPolicy[K]{
+		window:       newKeyList[K](),
+		probation:    newKeyList[K](),
+		protected:    newKeyList[K](),
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: max(mainCap*8/10, 1),
+		sketch:       newCountMinSketch[K](capacity),
+	}
+}
+
+// time-complexity: O(1)
+func (p *TinyLFUPolicy[K]) Add(key K) {
+	if p.window.has(key) || p.probation.has(key) || p.protected.has(key) {
+		p.Access(key)
+		return
+	}
+	p.sketch.increment(key)
+	p.window.push(key)
+	// Until the main segment fills up, window overflow moves in freely
+	if p.window.len() > p.windowCap && p.probation.len()+p.protected.len() < p.mainCap {
+		moved, _ := p.window.pop()
+		p.probation.push(moved)
+	}
+}
+
+// time-complexity: O(1)
+func (p *TinyLFUPolicy[K]) Access(key K) {
+	p.sketch.increment(key)
+	switch {
+	case p.window.has(key):
+		p.window.push(key)
+	case p.probation.remove(key):
+		p.protected.push(key)
+		if p.protected.len() > p.protectedCap {
+			demoted, _ := p.protected.pop()
+			p.probation.push(demoted)
+		}
+	case p.protected.has(key):
+		p.protected.push(key)
+	}
+}
+
+// time-complexity: O(1)
+func (p *TinyLFUPolicy[K]) Remove(key K) {
+	if !p.window.remove(key) && !p.probation.remove(key) {
+		p.protected.remove(key)
+	}
+}
+
+// time-complexity: O(1)
+func (p *TinyLFUPolicy[K]) Evict() (key K, ok bool) {
+	if p.window.len() > p.windowCap {
+		candidate, victim, victims, admit := p.duel()
+		p.window.remove(candidate)
+		if !admit {
+			return candidate, true
+		}
+		victims.remove(victim)
+		p.probation.push(candidate)
+		return victim, true
+	}
+	for _, l := range []*keyList[K]{&p.probation, &p.protected, &p.window} {
+		if key, ok = l.pop(); ok {
+			return key, true
+		}
+	}
+	return key, false
+}
+
+// time-complexity: O(1)
+func (p *TinyLFUPolicy[K]) Peek() (key K, ok bool) {
+	if p.window.len() > p.windowCap {
+		candidate, victim, _, admit := p.duel()
+		if admit {
+			return victim, true
+		}
+		return candidate, true
+	}
+	for _, l := range []*keyList[K]{&p.probation, &p.protected, &p.window} {
+		if key, ok = l.oldest(); ok {
+			return key, true
+		}
+	}
+	return key, false
+}
+
+// duel returns the oldest window key, the main segment key it competes
+// with and the list holding it, and whether the window key is admitted.
+func (p *TinyLFUPolicy[K]) duel() (candidate, victim K, victims *keyList[K], admit bool) {
+	candidate, _ = p.window.oldest()
+	victim, ok := p.probation.oldest()
+	victims = &p.probation
+	if !ok {
+		victim, ok = p.protected.oldest()
+		victims = &p.protected
+	}
+	admit = ok && p.sketch.estimate(candidate) > p.sketch.estimate(victim)
+	return candidate, victim, victims, admit
+}
+
+// time-complexity: O(1)
+func (p *TinyLFUPolicy[K]) Clear() {
+	p.window.clear()
+	p.probation.clear()
+	p.protected.clear()
+	p.sketch.reset()
+}
+
+// countMinSketch estimates how often keys were seen with 4 rows of
+// saturating 4-bit counters. All counters are halved every sampleSize
+// increments so old popularity fades.
+type countMinSketch[K comparable] struct {
+	rows       [4][]uint8
+	mask       uint64
+	seed       maphash.Seed
+	additions  int
+	sampleSize int
+}
+
+// newCountMinSketch sizes the sketch so that a sample of 10 increments per
+// cached entry leaves the average counter around 2.
+func newCountMinSketch[K comparable](capacity int) *countMinSketch[K] {
+	width := 16
+	for width < 4*capacity {
+		width <<= 1
+	}
+	s := &countMinSketch[K]{
+		mask:       uint64(width - 1),
+		seed:       maphash.MakeSeed(),
+		sampleSize: 10 * capacity,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *countMinSketch[K]) increment(key K) {
+	h := maphash.Comparable(s.seed, key)
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < 15 {
+			*c++
+		}
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.age()
+	}
+}
+
+func (s *countMinSketch[K]) estimate(key K) uint8 {
+	h := maphash.Comparable(s.seed, key)
+	est := uint8(15)
+	for i := range s.rows {
+		est = min(est, s.rows[i][s.index(h, i)])
+	}
+	return est
+}
+
+// index derives row i's counter from one hash by double hashing.
+func (s *countMinSketch[K]) index(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|1)) & s.mask
+}
+
+func (s *countMinSketch[K]) age() {
+	s.additions /= 2
+	for _, row := range s.rows {
+		for j := range row {
+			row[j] >>= 1
+		}
+	}
+}
+
+func (s *countMinSketch[K]) reset() {
+	s.additions = 0
+	for _, row := range s.rows {
+		clear(row)
+	}
+}
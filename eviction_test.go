@@ -0,0 +1,176 @@
+package structures_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+var evictionKinds = map[string]structures.EvictionKind{
+	"LRU":     structures.EvictionLRU,
+	"LFU":     structures.EvictionLFU,
+	"ARC":     structures.EvictionARC,
+	"TinyLFU": structures.EvictionTinyLFU,
+}
+
+func TestLFUPolicy(t *testing.T) {
+	is := is.New(t)
+	p := structures.NewLFUPolicy[string]()
+	p.Add("a")
+	p.Add("b")
+	p.Add("c")
+	p.Access("a")
+	p.Access("a")
+	p.Access("c")
+
+	key, _ := p.Evict()
+	is.Equal(key, "b")
+	p.Remove("c")
+	key, _ = p.Evict()
+	is.Equal(key, "a")
+	_, ok := p.Evict()
+	is.True(!ok)
+}
+
+func TestARCPolicyGhostHit(t *testing.T) {
+	is := is.New(t)
+	p := structures.NewARCPolicy[int](2)
+	p.Add(1)
+	p.Add(2)
+	p.Add(3)
+	key, _ := p.Evict()
+	is.Equal(key, 1)
+
+	p.Add(1) // ghost hit, 1 comes back as frequent
+	key, _ = p.Evict()
+	is.Equal(key, 2)
+	p.Add(4)
+	key, _ = p.Evict()
+	is.Equal(key, 3)
+}
+
+func TestTinyLFUPolicyAdmission(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewCacheMap[int, int](time.Hour,
+		structures.MaxEntriesCacheOpt(100),
+		structures.EvictionCacheOpt(structures.EvictionTinyLFU),
+	)
+	for i := range 100 {
+		cache.Add(i, i)
+	}
+	for range 5 {
+		for i := range 100 {
+			cache.Get(i)
+		}
+	}
+	for i := 1000; i < 2000; i++ { // a one-off scan while hot keys keep being read
+		cache.Add(i, i)
+		cache.Get(i % 100)
+	}
+	hot := 0
+	for i := range 100 {
+		if cache.Has(i) {
+			hot++
+		}
+	}
+	is.True(hot >= 95) // the scan didn't push out the hot keys
+	is.Equal(cache.Len(), 100)
+}
+
+func TestCacheMapEvictionPolicies(t *testing.T) {
+	for name, kind := range evictionKinds {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			cache := structures.NewCacheMap[int, int](time.Hour,
+				structures.MaxEntriesCacheOpt(50),
+				structures.EvictionCacheOpt(kind),
+			)
+			r := rand.New(rand.NewPCG(1, 2))
+			for range 10_000 {
+				k := r.IntN(200)
+				switch r.IntN(10) {
+				case 0:
+					cache.Delete(k)
+				case 1, 2, 3:
+					cache.Add(k, k)
+				default:
+					if v, ok := cache.Get(k); ok {
+						is.Equal(v, k)
+					}
+				}
+				is.True(cache.Len() <= 50)
+			}
+			stats := cache.Stats()
+			is.True(stats.Hits() > 0)
+			is.True(stats.Evictions() > 0)
+
+			cache.Clear()
+			for i := range 50 {
+				cache.Add(i, i)
+			}
+			is.Equal(cache.Len(), 50)
+		})
+	}
+}
+
+func TestCacheMapSetEvictionPolicy(t *testing.T) {
+	is := is.New(t)
+	cache := structures.NewCacheMap[int, int](time.Hour, structures.MaxEntriesCacheOpt(2))
+	cache.Add(1, 1)
+	cache.Add(2, 2)
+	cache.SetEvictionPolicy(structures.NewLFUPolicy[int]())
+	cache.Get(2)
+	cache.Add(3, 3)
+	is.True(!cache.Has(1))
+	is.True(cache.Has(2))
+}
+
+// zipfScanTrace returns a key trace where most requests follow a Zipf
+// distribution over a hot set and every so often a long scan of keys that
+// are never requested again passes through, which is what thrashes LRU.
+func zipfScanTrace(n int) []int {
+	r := rand.New(rand.NewPCG(42, 42))
+	zipf := rand.NewZipf(r, 1.1, 1, 10_000)
+	trace := make([]int, 0, n)
+	scan := 1_000_000
+	for len(trace) < n {
+		if r.IntN(2000) == 0 {
+			for range 500 {
+				trace = append(trace, scan)
+				scan++
+			}
+			continue
+		}
+		trace = append(trace, int(zipf.Uint64()))
+	}
+	return trace[:n]
+}
+
+// BenchmarkEvictionPolicies replays a key trace against a bounded CacheMap,
+// loading on every miss, and reports the hit ratio of each policy.
+func BenchmarkEvictionPolicies(b *testing.B) {
+	trace := zipfScanTrace(200_000)
+	for _, name := range []string{"LRU", "LFU", "ARC", "TinyLFU"} {
+		for _, size := range []int{100, 1000} {
+			b.Run(fmt.Sprintf("%s/%d", name, size), func(b *testing.B) {
+				cache := structures.NewCacheMap[int, int](time.Hour,
+					structures.MaxEntriesCacheOpt(size),
+					structures.EvictionCacheOpt(evictionKinds[name]),
+				)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					k := trace[i%len(trace)]
+					if _, ok := cache.Get(k); !ok {
+						cache.Add(k, k)
+					}
+				}
+				b.ReportMetric(cache.Stats().HitRatio(), "hit-ratio")
+			})
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package structures
+
+import (
+	"errors"
+	"os"
+)
+
+var errFileLockUnsupported = errors.New("file locking is not supported on this platform")
+
+func lockFile(f *os.File, exclusive bool) error {
+	return errFileLockUnsupported
+}
+
+func unlockFile(f *os.File) error {
+	return errFileLockUnsupported
+}
@@ -1,5 +1,5 @@
 module github.com/stevo-go-utils/structures
 
-go 1.21.5
+go 1.24
 
 require github.com/matryer/is v1.4.1
@@ -0,0 +1,168 @@
+package structures
+
+import (
+	"container/heap"
+	"time"
+)
+
+// janitor deletes expired keys for a cache. Expiries are kept in a min-heap
+// and a single Clock timer is armed for the earliest one, so a cache with a
+// million keys has one pending timer instead of a million. A janitor has no
+// lock of its own; every method must be called with the owning cache's
+// mutex held, including from inside sweep. A nil janitor does nothing.
+type janitor[K comparable] struct {
+	queue  expiryQueue[K]
+	clock  Clock
+	timer  Timer
+	armed  time.Time
+	closed bool
+	sweep  func()
+}
+
+// newJanitor returns a janitor that calls sweep whenever the earliest
+// scheduled expiry is reached. sweep should lock the cache, call due and
+// delete the keys it returns.
+func newJanitor[K comparable](clock Clock, sweep func()) *janitor[K] {
+	return &janitor[K]{
+		queue: expiryQueue[K]{index: map[K]*expiryItem[K]{}},
+		clock: clock,
+		sweep: sweep,
+	}
+}
+
+// schedule sets key to expire at the given time, replacing any earlier schedule.
+// time-complexity: O(log n)
+func (j *janitor[K]) schedule(key K, at time.Time) {
+	if j == nil || j.closed {
+		return
+	}
+	j.queue.set(key, at)
+	j.arm()
+}
+
+// unschedule forgets key. The timer isn't rearmed, waking up early is harmless.
+// time-complexity: O(log n)
+func (j *janitor[K]) unschedule(key K) {
+	if j == nil {
+		return
+	}
+	j.queue.remove(key)
+}
+
+// clear forgets every key.
+// time-complexity: O(1)
+func (j *janitor[K]) clear() {
+	if j == nil {
+		return
+	}
+	j.queue.clear()
+}
+
+// due removes and returns every key expiring at or before now and rearms the
+// timer for the next one.
+// time-complexity: O(k log n) for k due keys
+func (j *janitor[K]) due(now time.Time) (keys []K) {
+	if j == nil {
+		return nil
+	}
+	j.armed = time.Time{}
+	for j.queue.Len() > 0 && !j.queue.items[0].at.After(now) {
+		keys = append(keys, heap.Pop(&j.queue).(*expiryItem[K]).key)
+	}
+	j.arm()
+	return keys
+}
+
+// close stops the timer for good. Later schedules are ignored.
+func (j *janitor[K]) close() {
+	if j == nil || j.closed {
+		return
+	}
+	j.closed = true
+	if j.timer != nil {
+		j.timer.Stop()
+	}
+	j.queue.clear()
+}
+
+// arm makes sure the timer fires no later than the earliest expiry.
+func (j *janitor[K]) arm() {
+	if j.closed || j.queue.Len() == 0 {
+		return
+	}
+	next := j.queue.items[0].at
+	if !j.armed.IsZero() && !next.Before(j.armed) {
+		return
+	}
+	j.armed = next
+	d := next.Sub(j.clock.Now())
+	if j.timer == nil {
+		j.timer = j.clock.AfterFunc(d, j.sweep)
+		return
+	}
+	j.timer.Reset(d)
+}
+
+type expiryItem[K comparable] struct {
+	key K
+	at  time.Time
+	pos int
+}
+
+// expiryQueue is a heap.Interface of keys ordered by expiry with an index
+// for updating or removing a key in O(log n). Items are pointers that know
+// their own position so swaps don't touch the index map.
+type expiryQueue[K comparable] struct {
+	items []*expiryItem[K]
+	index map[K]*expiryItem[K]
+}
+
+func (q *expiryQueue[K]) Len() int {
+	return len(q.items)
+}
+
+func (q *expiryQueue[K]) Less(i, j int) bool {
+	return q.items[i].at.Before(q.items[j].at)
+}
+
+func (q *expiryQueue[K]) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].pos = i
+	q.items[j].pos = j
+}
+
+func (q *expiryQueue[K]) Push(x any) {
+	item := x.(*expiryItem[K])
+	item.pos = len(q.items)
+	q.items = append(q.items, item)
+	q.index[item.key] = item
+}
+
+func (q *expiryQueue[K]) Pop() any {
+	last := len(q.items) - 1
+	item := q.items[last]
+	q.items[last] = nil
+	q.items = q.items[:last]
+	delete(q.index, item.key)
+	return item
+}
+
+func (q *expiryQueue[K]) set(key K, at time.Time) {
+	if item, ok := q.index[key]; ok {
+		item.at = at
+		heap.Fix(q, item.pos)
+		return
+	}
+	heap.Push(q, &expiryItem[K]{key: key, at: at})
+}
+
+func (q *expiryQueue[K]) remove(key K) {
+	if item, ok := q.index[key]; ok {
+		heap.Remove(q, item.pos)
+	}
+}
+
+func (q *expiryQueue[K]) clear() {
+	q.items = nil
+	clear(q.index)
+}
@@ -0,0 +1,639 @@
+package structures
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// LinkedList is a non-circular singly linked list.
+type LinkedList[T comparable] struct {
+	head *Node[T]
+	tail *Node[T]
+	size int
+	pool *NodePool[T]
+}
+
+// NewLinkedList constructs a linked-list holding vals in order.
+// time-complexity: O(n)
+func NewLinkedList[T comparable](vals ...T) *LinkedList[T] {
+	l := &LinkedList[T]{}
+	for _, v := range vals {
+		l.AddLast(v)
+	}
+	return l
+}
+
+// SetNodePool makes the list take nodes from and return removed nodes to pool.
+// time-complexity: O(1)
+func (l *LinkedList[T]) SetNodePool(pool *NodePool[T]) {
+	l.pool = pool
+}
+
+// IsEmpty returns true if the linked-list doesn't contain any nodes.
+// time-complexity: O(1)
+func (l *LinkedList[T]) IsEmpty() bool {
+	return l.size == 0
+}
+
+// Len returns the number of nodes in the list.
+// time-complexity: O(1)
+func (l *LinkedList[T]) Len() int {
+	return l.size
+}
+
+// First returns the first element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (l *LinkedList[T]) First() (data T, ok bool) {
+	if l.IsEmpty() {
+		return
+	}
+	return l.head.Data, true
+}
+
+// Last returns the last element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (l *LinkedList[T]) Last() (data T, ok bool) {
+	if l.IsEmpty() {
+		return
+	}
+	return l.tail.Data, true
+}
+
+// AddFirst adds a new node to the beginning of the list.
+// time-complexity: O(1)
+func (l *LinkedList[T]) AddFirst(data T) {
+	n := l.pool.node(data, l.head)
+	l.head = n
+	if l.tail == nil {
+		l.tail = n
+	}
+	l.size++
+}
+
+// AddLast adds a new node to the end of the list.
+// time-complexity: O(1)
+func (l *LinkedList[T]) AddLast(data T) {
+	n := l.pool.node(data, nil)
+	if l.tail == nil {
+		l.head = n
+	} else {
+		l.tail.Next = n
+	}
+	l.tail = n
+	l.size++
+}
+
+// RemoveFirst removes and returns the first element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (l *LinkedList[T]) RemoveFirst() (val T, ok bool) {
+	if l.IsEmpty() {
+		return
+	}
+	n := l.head
+	l.head = n.Next
+	if l.head == nil {
+		l.tail = nil
+	}
+	l.size--
+	val = n.Data
+	l.pool.freeNode(n)
+	return val, true
+}
+
+// RemoveLast removes and returns the last element of the list. It returns false if the list is empty.
+// time-complexity: O(n)
+func (l *LinkedList[T]) RemoveLast() (val T, ok bool) {
+	if l.IsEmpty() {
+		return
+	}
+	val = l.tail.Data
+	removed := l.tail
+	if l.head == l.tail {
+		l.head = nil
+		l.tail = nil
+	} else {
+		prev := l.head
+		for prev.Next != l.tail {
+			prev = prev.Next
+		}
+		prev.Next = nil
+		l.tail = prev
+	}
+	l.size--
+	l.pool.freeNode(removed)
+	return val, true
+}
+
+// Reverse reverses the list in place.
+// time-complexity: O(n)
+func (l *LinkedList[T]) Reverse() {
+	var prev *Node[T]
+	for current := l.head; current != nil; {
+		next := current.Next
+		current.Next = prev
+		prev, current = current, next
+	}
+	l.head, l.tail = l.tail, l.head
+}
+
+// SortFunc sorts the list in place with a stable merge sort. cmp returns a
+// negative number when a < b, a positive number when a > b and zero otherwise.
+// time-complexity: O(n log n), space-complexity: O(log n)
+func (l *LinkedList[T]) SortFunc(cmp func(a, b T) int) {
+	l.head = mergeSortNodes(l.head, l.size, cmp)
+	for l.tail = l.head; l.tail != nil && l.tail.Next != nil; l.tail = l.tail.Next {
+	}
+}
+
+// Dedupe removes every element that already occurred earlier in the list.
+// time-complexity: O(n)
+func (l *LinkedList[T]) Dedupe() (removed int) {
+	seen := make(map[T]struct{}, l.size)
+	return l.Filter(func(v T) bool {
+		if _, ok := seen[v]; ok {
+			return false
+		}
+		seen[v] = struct{}{}
+		return true
+	})
+}
+
+// Filter removes every element for which keep returns false and returns how many nodes were removed.
+// time-complexity: O(n)
+func (l *LinkedList[T]) Filter(keep func(T) bool) (removed int) {
+	var prev *Node[T]
+	for current := l.head; current != nil; {
+		next := current.Next
+		if keep(current.Data) {
+			prev = current
+			current = next
+			continue
+		}
+		if prev == nil {
+			l.head = next
+		} else {
+			prev.Next = next
+		}
+		if current == l.tail {
+			l.tail = prev
+		}
+		l.size--
+		l.pool.freeNode(current)
+		removed++
+		current = next
+	}
+	return removed
+}
+
+// Find returns the first element for which pred returns true.
+// time-complexity: O(n)
+func (l *LinkedList[T]) Find(pred func(T) bool) (val T, ok bool) {
+	for current := l.head; current != nil; current = current.Next {
+		if pred(current.Data) {
+			return current.Data, true
+		}
+	}
+	return
+}
+
+// Vals returns the elements of the list as a slice.
+// time-complexity: O(n)
+func (l *LinkedList[T]) Vals() []T {
+	vals := make([]T, 0, l.size)
+	for current := l.head; current != nil; current = current.Next {
+		vals = append(vals, current.Data)
+	}
+	return vals
+}
+
+// All returns an iterator over the indexes and elements of the list. The
+// list must not be modified while iterating.
+// time-complexity: O(n)
+func (l *LinkedList[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		i := 0
+		for current := l.head; current != nil; current = current.Next {
+			if !yield(i, current.Data) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// MarshalJSON encodes the list as a JSON array, first to last.
+// time-complexity: O(n)
+func (l LinkedList[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.slice())
+}
+
+// UnmarshalJSON replaces the list with the elements of a JSON array.
+// time-complexity: O(n)
+func (l *LinkedList[T]) UnmarshalJSON(data []byte) error {
+	var vals []T
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	l.reset(vals)
+	return nil
+}
+
+// MarshalBinary encodes the list with encoding/gob.
+// time-complexity: O(n)
+func (l LinkedList[T]) MarshalBinary() ([]byte, error) {
+	return gobEncode(l.slice())
+}
+
+// UnmarshalBinary replaces the list with gob encoded elements.
+// time-complexity: O(n)
+func (l *LinkedList[T]) UnmarshalBinary(data []byte) error {
+	var vals []T
+	if err := gobDecode(data, &vals); err != nil {
+		return err
+	}
+	l.reset(vals)
+	return nil
+}
+
+func (l *LinkedList[T]) slice() []T {
+	vals := l.Vals()
+	if vals == nil {
+		vals = []T{}
+	}
+	return vals
+}
+
+// reset replaces the elements with vals, returning removed nodes to the pool.
+func (l *LinkedList[T]) reset(vals []T) {
+	for !l.IsEmpty() {
+		l.RemoveFirst()
+	}
+	for _, v := range vals {
+		l.AddLast(v)
+	}
+}
+
+// String returns the string representation of the list.
+// time-complexity: O(n)
+func (l *LinkedList[T]) String() string {
+	return listString(l.All())
+}
+
+// DoublyLinkedList is a non-circular doubly linked list. Add methods return
+// node handles for O(1) removal.
+type DoublyLinkedList[T comparable] struct {
+	head *DoublyNode[T]
+	tail *DoublyNode[T]
+	size int
+	pool *NodePool[T]
+}
+
+// NewDoublyLinkedList constructs a doubly linked-list holding vals in order.
+// time-complexity: O(n)
+func NewDoublyLinkedList[T comparable](vals ...T) *DoublyLinkedList[T] {
+	l := &DoublyLinkedList[T]{}
+	for _, v := range vals {
+		l.AddLast(v)
+	}
+	return l
+}
+
+// SetNodePool makes the list take nodes from and return removed nodes to
+// pool. Handles must not be used after their element was removed.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) SetNodePool(pool *NodePool[T]) {
+	l.pool = pool
+}
+
+// IsEmpty returns true if the linked-list doesn't contain any nodes.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) IsEmpty() bool {
+	return l.size == 0
+}
+
+// Len returns the number of nodes in the list.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) Len() int {
+	return l.size
+}
+
+// First returns the first element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) First() (data T, ok bool) {
+	if l.IsEmpty() {
+		return
+	}
+	return l.head.Data, true
+}
+
+// Last returns the last element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) Last() (data T, ok bool) {
+	if l.IsEmpty() {
+		return
+	}
+	return l.tail.Data, true
+}
+
+// AddFirst adds a new node to the beginning of the list and returns its handle.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) AddFirst(data T) *DoublyNode[T] {
+	n := l.pool.doublyNode(data)
+	n.Next = l.head
+	n.list = l
+	if l.head == nil {
+		l.tail = n
+	} else {
+		l.head.Prev = n
+	}
+	l.head = n
+	l.size++
+	return n
+}
+
+// AddLast adds a new node to the end of the list and returns its handle.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) AddLast(data T) *DoublyNode[T] {
+	n := l.pool.doublyNode(data)
+	n.Prev = l.tail
+	n.list = l
+	if l.tail == nil {
+		l.head = n
+	} else {
+		l.tail.Next = n
+	}
+	l.tail = n
+	l.size++
+	return n
+}
+
+// Remove removes the node from the list and returns its data. It returns
+// false if the node doesn't belong to the list.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) Remove(n *DoublyNode[T]) (val T, ok bool) {
+	if n == nil || n.list != l {
+		return
+	}
+	l.unlink(n)
+	val = n.Data
+	l.pool.freeDoublyNode(n)
+	return val, true
+}
+
+// RemoveFirst removes and returns the first element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) RemoveFirst() (val T, ok bool) {
+	return l.Remove(l.head)
+}
+
+// RemoveLast removes and returns the last element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (l *DoublyLinkedList[T]) RemoveLast() (val T, ok bool) {
+	return l.Remove(l.tail)
+}
+
+// Reverse reverses the list in place. Handles stay valid.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) Reverse() {
+	for current := l.head; current != nil; current = current.Prev {
+		current.Next, current.Prev = current.Prev, current.Next
+	}
+	l.head, l.tail = l.tail, l.head
+}
+
+// SortFunc sorts the list in place with a stable merge sort. Handles stay
+// valid. cmp returns a negative number when a < b, a positive number when
+// a > b and zero otherwise.
+// time-complexity: O(n log n), space-complexity: O(log n)
+func (l *DoublyLinkedList[T]) SortFunc(cmp func(a, b T) int) {
+	l.head = mergeSortNodes(l.head, l.size, cmp)
+	var prev *DoublyNode[T]
+	for current := l.head; current != nil; current = current.Next {
+		current.Prev = prev
+		prev = current
+	}
+	l.tail = prev
+}
+
+// Dedupe removes every element that already occurred earlier in the list.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) Dedupe() (removed int) {
+	seen := make(map[T]struct{}, l.size)
+	return l.Filter(func(v T) bool {
+		if _, ok := seen[v]; ok {
+			return false
+		}
+		seen[v] = struct{}{}
+		return true
+	})
+}
+
+// Filter removes every element for which keep returns false and returns how many nodes were removed.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) Filter(keep func(T) bool) (removed int) {
+	for current := l.head; current != nil; {
+		next := current.Next
+		if !keep(current.Data) {
+			l.unlink(current)
+			l.pool.freeDoublyNode(current)
+			removed++
+		}
+		current = next
+	}
+	return removed
+}
+
+// Find returns the handle of the first element for which pred returns true, or nil.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) Find(pred func(T) bool) *DoublyNode[T] {
+	for current := l.head; current != nil; current = current.Next {
+		if pred(current.Data) {
+			return current
+		}
+	}
+	return nil
+}
+
+// Vals returns the elements of the list as a slice.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) Vals() []T {
+	vals := make([]T, 0, l.size)
+	for current := l.head; current != nil; current = current.Next {
+		vals = append(vals, current.Data)
+	}
+	return vals
+}
+
+// All returns an iterator over the indexes and elements of the list. The
+// list must not be modified while iterating.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		i := 0
+		for current := l.head; current != nil; current = current.Next {
+			if !yield(i, current.Data) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// Backward returns an iterator over the indexes and elements of the list, last to first.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		i := l.size - 1
+		for current := l.tail; current != nil; current = current.Prev {
+			if !yield(i, current.Data) {
+				return
+			}
+			i--
+		}
+	}
+}
+
+// MarshalJSON encodes the list as a JSON array, first to last.
+// time-complexity: O(n)
+func (l DoublyLinkedList[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.slice())
+}
+
+// UnmarshalJSON replaces the list with the elements of a JSON array.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) UnmarshalJSON(data []byte) error {
+	var vals []T
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	l.reset(vals)
+	return nil
+}
+
+// MarshalBinary encodes the list with encoding/gob.
+// time-complexity: O(n)
+func (l DoublyLinkedList[T]) MarshalBinary() ([]byte, error) {
+	return gobEncode(l.slice())
+}
+
+// UnmarshalBinary replaces the list with gob encoded elements.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) UnmarshalBinary(data []byte) error {
+	var vals []T
+	if err := gobDecode(data, &vals); err != nil {
+		return err
+	}
+	l.reset(vals)
+	return nil
+}
+
+func (l *DoublyLinkedList[T]) slice() []T {
+	vals := l.Vals()
+	if vals == nil {
+		vals = []T{}
+	}
+	return vals
+}
+
+// reset replaces the elements with vals, returning removed nodes to the pool.
+func (l *DoublyLinkedList[T]) reset(vals []T) {
+	for !l.IsEmpty() {
+		l.RemoveFirst()
+	}
+	for _, v := range vals {
+		l.AddLast(v)
+	}
+}
+
+// String returns the string representation of the list.
+// time-complexity: O(n)
+func (l *DoublyLinkedList[T]) String() string {
+	return listString(l.All())
+}
+
+func (l *DoublyLinkedList[T]) unlink(n *DoublyNode[T]) {
+	if n.Prev == nil {
+		l.head = n.Next
+	} else {
+		n.Prev.Next = n.Next
+	}
+	if n.Next == nil {
+		l.tail = n.Prev
+	} else {
+		n.Next.Prev = n.Prev
+	}
+	n.Next = nil
+	n.Prev = nil
+	n.list = nil
+	l.size--
+}
+
+func listString[T any](all iter.Seq2[int, T]) string {
+	var b strings.Builder
+	b.WriteString("[ ")
+	for _, v := range all {
+		b.WriteString(fmt.Sprint(v))
+		b.WriteString(" ")
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// chainNode is a node linked forward by Next, so Node and DoublyNode share
+// one merge sort.
+type chainNode[T, N any] interface {
+	comparable
+	data() T
+	next() N
+	setNext(N)
+}
+
+// mergeSortNodes sorts the n nodes starting at head by their Next links and
+// returns the new head. DoublyNode Prev links are left for the caller to fix.
+func mergeSortNodes[T any, N chainNode[T, N]](head N, n int, cmp func(a, b T) int) N {
+	var none N
+	if n <= 1 {
+		if head != none {
+			head.setNext(none)
+		}
+		return head
+	}
+	mid := head
+	for i := 1; i < n/2; i++ {
+		mid = mid.next()
+	}
+	right := mid.next()
+	mid.setNext(none)
+	left := mergeSortNodes(head, n/2, cmp)
+	right = mergeSortNodes(right, n-n/2, cmp)
+
+	var first, tail N
+	link := func(node N) {
+		if tail == none {
+			first = node
+		} else {
+			tail.setNext(node)
+		}
+		tail = node
+	}
+	for left != none && right != none {
+		// Take from the left on ties to keep the sort stable
+		if cmp(left.data(), right.data()) <= 0 {
+			next := left.next()
+			link(left)
+			left = next
+		} else {
+			next := right.next()
+			link(right)
+			right = next
+		}
+	}
+	if left != none {
+		tail.setNext(left)
+	} else {
+		tail.setNext(right)
+	}
+	return first
+}
@@ -0,0 +1,86 @@
+package structures_test
+
+import (
+	"cmp"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+type sortItem struct {
+	key   int
+	order int
+}
+
+func TestLinkedList(t *testing.T) {
+	is := is.New(t)
+	list := structures.NewLinkedList(3, 1, 2, 3, 1)
+	is.Equal(list.Dedupe(), 2)
+	is.Equal(list.Vals(), []int{3, 1, 2})
+
+	list.Reverse()
+	is.Equal(list.Vals(), []int{2, 1, 3})
+	list.AddLast(0)
+	is.Equal(list.Vals(), []int{2, 1, 3, 0})
+
+	list.SortFunc(cmp.Compare[int])
+	is.Equal(list.Vals(), []int{0, 1, 2, 3})
+	last, _ := list.Last()
+	is.Equal(last, 3)
+
+	v, ok := list.Find(func(v int) bool { return v > 1 })
+	is.True(ok)
+	is.Equal(v, 2)
+
+	is.Equal(list.Filter(func(v int) bool { return v%2 == 0 }), 2)
+	is.Equal(list.Vals(), []int{0, 2})
+	v, _ = list.RemoveLast()
+	is.Equal(v, 2)
+	list.AddLast(4)
+	is.Equal(list.String(), "[ 0 4 ]")
+}
+
+func TestLinkedListSortStable(t *testing.T) {
+	is := is.New(t)
+	items := []sortItem{{2, 0}, {1, 1}, {2, 2}, {1, 3}, {0, 4}}
+	byKey := func(a, b sortItem) int { return cmp.Compare(a.key, b.key) }
+
+	list := structures.NewLinkedList(items...)
+	list.SortFunc(byKey)
+	is.Equal(list.Vals(), []sortItem{{0, 4}, {1, 1}, {1, 3}, {2, 0}, {2, 2}})
+
+	doubly := structures.NewDoublyLinkedList(items...)
+	doubly.SortFunc(byKey)
+	is.Equal(doubly.Vals(), []sortItem{{0, 4}, {1, 1}, {1, 3}, {2, 0}, {2, 2}})
+	backward := []sortItem{}
+	for _, v := range doubly.Backward() {
+		backward = append(backward, v)
+	}
+	is.Equal(backward, []sortItem{{2, 2}, {2, 0}, {1, 3}, {1, 1}, {0, 4}})
+}
+
+func TestDoublyLinkedList(t *testing.T) {
+	is := is.New(t)
+	list := structures.NewDoublyLinkedList[string]()
+	b := list.AddLast("b")
+	list.AddFirst("a")
+	list.AddLast("c")
+	list.AddLast("a")
+
+	val, ok := list.Remove(b)
+	is.True(ok)
+	is.Equal(val, "b")
+	is.Equal(list.Dedupe(), 1)
+	is.Equal(list.Vals(), []string{"a", "c"})
+
+	list.Reverse()
+	is.Equal(list.Vals(), []string{"c", "a"})
+	n := list.Find(func(v string) bool { return v == "a" })
+	is.True(n != nil)
+	list.Remove(n)
+	is.Equal(list.Len(), 1)
+	last, _ := list.RemoveLast()
+	is.Equal(last, "c")
+	is.True(list.IsEmpty())
+}
@@ -12,3 +12,26 @@ type Node[T any] struct {
 func (n *Node[T]) String() string {
 	return fmt.Sprint(n.Data)
 }
+
+func (n *Node[T]) data() T               { return n.Data }
+func (n *Node[T]) next() *Node[T]        { return n.Next }
+func (n *Node[T]) setNext(next *Node[T]) { n.Next = next }
+
+// DoublyNode is a node of a doubly linked list. Lists hand them out as
+// handles so elements can be removed or moved without searching.
+type DoublyNode[T any] struct {
+	Data T
+	Next *DoublyNode[T]
+	Prev *DoublyNode[T]
+	list any
+}
+
+// String returns the string representation of the node's data.
+// time-complexity: O(1)
+func (n *DoublyNode[T]) String() string {
+	return fmt.Sprint(n.Data)
+}
+
+func (n *DoublyNode[T]) data() T                     { return n.Data }
+func (n *DoublyNode[T]) next() *DoublyNode[T]        { return n.Next }
+func (n *DoublyNode[T]) setNext(next *DoublyNode[T]) { n.Next = next }
@@ -0,0 +1,59 @@
+package structures
+
+import "sync"
+
+// NodePool recycles list nodes to cut garbage under heavy Add/Remove churn.
+// It is backed by sync.Pool, is safe for concurrent use and can be shared by
+// any number of lists with the same element type.
+//
+// A list using a pool reuses removed nodes, so handles (*DoublyNode) must
+// not be used after their element was removed.
+type NodePool[T any] struct {
+	nodes       sync.Pool
+	doublyNodes sync.Pool
+}
+
+func NewNodePool[T any]() *NodePool[T] {
+	return &NodePool[T]{}
+}
+
+func (p *NodePool[T]) node(data T, next *Node[T]) *Node[T] {
+	if p == nil {
+		return &Node[T]{Data: data, Next: next}
+	}
+	n, ok := p.nodes.Get().(*Node[T])
+	if !ok {
+		n = &Node[T]{}
+	}
+	n.Data = data
+	n.Next = next
+	return n
+}
+
+func (p *NodePool[T]) freeNode(n *Node[T]) {
+	if p == nil {
+		return
+	}
+	*n = Node[T]{}
+	p.nodes.Put(n)
+}
+
+func (p *NodePool[T]) doublyNode(data T) *DoublyNode[T] {
+	if p == nil {
+		return &DoublyNode[T]{Data: data}
+	}
+	n, ok := p.doublyNodes.Get().(*DoublyNode[T])
+	if !ok {
+		n = &DoublyNode[T]{}
+	}
+	n.Data = data
+	return n
+}
+
+func (p *NodePool[T]) freeDoublyNode(n *DoublyNode[T]) {
+	if p == nil {
+		return
+	}
+	*n = DoublyNode[T]{}
+	p.doublyNodes.Put(n)
+}
@@ -0,0 +1,76 @@
+package structures_test
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestNodePoolSharedByLists(t *testing.T) {
+	is := is.New(t)
+	pool := structures.NewNodePool[int]()
+	list := structures.NewCircularLinkedList[int]()
+	list.SetNodePool(pool)
+	other := structures.NewLinkedList[int]()
+	other.SetNodePool(pool)
+
+	for i := 0; i < 100; i++ {
+		list.AddLast(i)
+		other.AddLast(i)
+		if i%3 == 0 {
+			list.RemoveFirst()
+			other.RemoveLast()
+		}
+	}
+	is.Equal(list.Size(), 66)
+	is.Equal(other.Len(), 66)
+	first, _ := list.First()
+	is.Equal(first, 34)
+	last, _ := other.Last()
+	is.Equal(last, 98)
+}
+
+func benchmarkCLLChurn(b *testing.B, pool *structures.NodePool[int]) {
+	list := structures.NewCircularLinkedList[int]()
+	list.SetNodePool(pool)
+	for i := 0; i < 1024; i++ {
+		list.AddLast(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list.RemoveFirst()
+		list.AddLast(i)
+	}
+}
+
+func BenchmarkCircularLinkedListChurn(b *testing.B) {
+	benchmarkCLLChurn(b, nil)
+}
+
+func BenchmarkCircularLinkedListChurnNodePool(b *testing.B) {
+	benchmarkCLLChurn(b, structures.NewNodePool[int]())
+}
+
+func benchmarkDCLLChurn(b *testing.B, pool *structures.NodePool[int]) {
+	list := structures.NewDoublyCircularLinkedList[int]()
+	list.SetNodePool(pool)
+	for i := 0; i < 1024; i++ {
+		list.AddLast(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list.Remove(list.FirstNode())
+		list.AddLast(i)
+	}
+}
+
+func BenchmarkDoublyCircularLinkedListChurn(b *testing.B) {
+	benchmarkDCLLChurn(b, nil)
+}
+
+func BenchmarkDoublyCircularLinkedListChurnNodePool(b *testing.B) {
+	benchmarkDCLLChurn(b, structures.NewNodePool[int]())
+}
@@ -0,0 +1,375 @@
+package structures
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrPoolClosed = errors.New("pool closed")
+
+// Pool hands out reusable objects created by a factory. Objects are borrowed
+// with Get and must be given back with either Put (reuse) or Discard (destroy).
+// When MaxTotal objects are checked out, Get callers queue up and are served
+// in arrival order.
+type Pool[T any] struct {
+	factory   func(context.Context) (T, error)
+	validate  func(T) bool
+	onCreate  func(T)
+	onDestroy func(T)
+	idle      []poolItem[T]
+	waiters   []chan poolGrant[T]
+	stats     PoolStats
+	closed    bool
+	stopCh    chan struct{}
+	mu        sync.Mutex
+	*PoolOpts
+}
+
+type poolItem[T any] struct {
+	val      T
+	idleFrom time.Time
+}
+
+// poolGrant is sent to a waiter. It either carries an object or, when
+// hasVal is false, the right to create a new one.
+type poolGrant[T any] struct {
+	val    T
+	hasVal bool
+	closed bool
+}
+
+type PoolStats struct {
+	total     int
+	idle      int
+	waiting   int
+	created   int
+	destroyed int
+	waits     int
+}
+
+// Total returns the number of live objects (idle and checked out).
+func (p PoolStats) Total() int {
+	return p.total
+}
+
+func (p PoolStats) Idle() int {
+	return p.idle
+}
+
+func (p PoolStats) InUse() int {
+	return p.total - p.idle
+}
+
+// Waiting returns the number of Get calls currently queued.
+func (p PoolStats) Waiting() int {
+	return p.waiting
+}
+
+func (p PoolStats) Created() int {
+	return p.created
+}
+
+func (p PoolStats) Destroyed() int {
+	return p.destroyed
+}
+
+// Waits returns how many Get calls had to queue because the pool was exhausted.
+func (p PoolStats) Waits() int {
+	return p.waits
+}
+
+type PoolOpts struct {
+	MaxIdle     int
+	MaxTotal    int
+	IdleTimeout *time.Duration
+	Clock       Clock
+}
+
+type PoolOpt func(*PoolOpts)
+
+func DefaultPoolOpts() *PoolOpts {
+	return &PoolOpts{
+		MaxIdle:     -1,
+		MaxTotal:    -1,
+		IdleTimeout: nil,
+		Clock:       RealClock(),
+	}
+}
+
+func MaxIdlePoolOpt(maxIdle int) PoolOpt {
+	return func(opts *PoolOpts) {
+		opts.MaxIdle = maxIdle
+	}
+}
+
+func MaxTotalPoolOpt(maxTotal int) PoolOpt {
+	return func(opts *PoolOpts) {
+		opts.MaxTotal = maxTotal
+	}
+}
+
+func IdleTimeoutPoolOpt(idleTimeout time.Duration) PoolOpt {
+	return func(opts *PoolOpts) {
+		opts.IdleTimeout = &idleTimeout
+	}
+}
+
+func ClockPoolOpt(clock Clock) PoolOpt {
+	return func(opts *PoolOpts) {
+		opts.Clock = clock
+	}
+}
+
+func NewPool[T any](factory func(context.Context) (T, error), opts ...PoolOpt) *Pool[T] {
+	o := DefaultPoolOpts()
+	for _, opt := range opts {
+		opt(o)
+	}
+	p := &Pool[T]{
+		factory:  factory,
+		stopCh:   make(chan struct{}),
+		PoolOpts: o,
+	}
+	if o.IdleTimeout != nil {
+		go p.evictIdle(*o.IdleTimeout)
+	}
+	return p
+}
+
+// SetValidate sets the func used to check idle objects on borrow. Objects
+// failing validation are destroyed and Get moves on to the next one.
+func (p *Pool[T]) SetValidate(fn func(T) bool) *Pool[T] {
+	p.validate = fn
+	return p
+}
+
+func (p *Pool[T]) SetOnCreate(fn func(T)) *Pool[T] {
+	p.onCreate = fn
+	return p
+}
+
+// SetOnDestroy sets the func called whenever an object leaves the pool for
+// good. Use it to release the underlying resource.
+func (p *Pool[T]) SetOnDestroy(fn func(T)) *Pool[T] {
+	p.onDestroy = fn
+	return p
+}
+
+func (p *Pool[T]) Get(ctx context.Context) (val T, err error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return val, ErrPoolClosed
+		}
+
+		// Reuse the most recently returned object
+		if n := len(p.idle); n > 0 {
+			item := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.stats.idle--
+			p.mu.Unlock()
+			if p.validate != nil && !p.validate(item.val) {
+				p.Discard(item.val)
+				continue
+			}
+			return item.val, nil
+		}
+
+		// Room to create a new object
+		if p.MaxTotal < 0 || p.stats.total < p.MaxTotal {
+			p.stats.total++
+			p.mu.Unlock()
+			return p.create(ctx)
+		}
+
+		// Exhausted, queue up behind earlier callers
+		ch := make(chan poolGrant[T], 1)
+		p.waiters = append(p.waiters, ch)
+		p.stats.waiting++
+		p.stats.waits++
+		p.mu.Unlock()
+
+		select {
+		case grant := <-ch:
+			if grant.closed {
+				return val, ErrPoolClosed
+			}
+			if !grant.hasVal {
+				return p.create(ctx)
+			}
+			return grant.val, nil
+		case <-ctx.Done():
+			p.mu.Lock()
+			if p.removeWaiter(ch) {
+				p.mu.Unlock()
+				return val, ctx.Err()
+			}
+			p.mu.Unlock()
+			// A grant was sent before we could leave the queue, pass it on
+			grant := <-ch
+			if !grant.closed {
+				if grant.hasVal {
+					p.Put(grant.val)
+				} else {
+					p.release()
+				}
+			}
+			return val, ctx.Err()
+		}
+	}
+}
+
+// Put returns a borrowed object to the pool. It is handed straight to the
+// longest waiting Get call if there is one.
+func (p *Pool[T]) Put(val T) {
+	p.mu.Lock()
+	if p.closed || (len(p.waiters) == 0 && p.MaxIdle >= 0 && len(p.idle) >= p.MaxIdle) {
+		p.stats.total--
+		p.stats.destroyed++
+		p.mu.Unlock()
+		p.destroy(val)
+		return
+	}
+	if ch, ok := p.popWaiter(); ok {
+		p.mu.Unlock()
+		ch <- poolGrant[T]{val: val, hasVal: true}
+		return
+	}
+	p.idle = append(p.idle, poolItem[T]{val: val, idleFrom: p.Clock.Now()})
+	p.stats.idle++
+	p.mu.Unlock()
+}
+
+// Discard destroys a borrowed object instead of returning it to the pool.
+func (p *Pool[T]) Discard(val T) {
+	p.mu.Lock()
+	p.stats.destroyed++
+	p.mu.Unlock()
+	p.release()
+	p.destroy(val)
+}
+
+func (p *Pool[T]) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Close destroys all idle objects and fails queued and future Get calls.
+// Objects still checked out are destroyed when they are Put back.
+func (p *Pool[T]) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stopCh)
+	idle := p.idle
+	p.idle = nil
+	p.stats.total -= len(idle)
+	p.stats.destroyed += len(idle)
+	p.stats.idle = 0
+	waiters := p.waiters
+	p.waiters = nil
+	p.stats.waiting = 0
+	p.mu.Unlock()
+
+	for _, ch := range waiters {
+		ch <- poolGrant[T]{closed: true}
+	}
+	for _, item := range idle {
+		p.destroy(item.val)
+	}
+}
+
+func (p *Pool[T]) create(ctx context.Context) (val T, err error) {
+	val, err = p.factory(ctx)
+	if err != nil {
+		p.release()
+		return val, err
+	}
+	p.mu.Lock()
+	p.stats.created++
+	p.mu.Unlock()
+	if p.onCreate != nil {
+		p.onCreate(val)
+	}
+	return val, nil
+}
+
+func (p *Pool[T]) destroy(val T) {
+	if p.onDestroy != nil {
+		p.onDestroy(val)
+	}
+}
+
+// release gives up a slot in MaxTotal. The slot goes to the longest waiting
+// Get call if there is one.
+func (p *Pool[T]) release() {
+	p.mu.Lock()
+	if ch, ok := p.popWaiter(); ok {
+		p.mu.Unlock()
+		ch <- poolGrant[T]{}
+		return
+	}
+	p.stats.total--
+	p.mu.Unlock()
+}
+
+func (p *Pool[T]) popWaiter() (ch chan poolGrant[T], ok bool) {
+	if len(p.waiters) == 0 {
+		return
+	}
+	ch = p.waiters[0]
+	p.waiters = p.waiters[1:]
+	p.stats.waiting--
+	return ch, true
+}
+
+func (p *Pool[T]) removeWaiter(ch chan poolGrant[T]) bool {
+	for i, w := range p.waiters {
+		if w == ch {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			p.stats.waiting--
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool[T]) evictIdle(idleTimeout time.Duration) {
+	interval := max(idleTimeout/2, time.Millisecond)
+	timer := p.Clock.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-timer.C():
+			now := p.Clock.Now()
+			p.mu.Lock()
+			var expired []T
+			kept := p.idle[:0]
+			for _, item := range p.idle {
+				if now.Sub(item.idleFrom) >= idleTimeout {
+					expired = append(expired, item.val)
+				} else {
+					kept = append(kept, item)
+				}
+			}
+			p.idle = kept
+			p.stats.idle -= len(expired)
+			p.stats.total -= len(expired)
+			p.stats.destroyed += len(expired)
+			p.mu.Unlock()
+			for _, val := range expired {
+				p.destroy(val)
+			}
+			timer.Reset(interval)
+		}
+	}
+}
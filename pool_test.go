@@ -0,0 +1,137 @@
+package structures_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func newTestPool(opts ...structures.PoolOpt) (*structures.Pool[int], *int) {
+	created := 0
+	pool := structures.NewPool(func(ctx context.Context) (int, error) {
+		created++
+		return created, nil
+	}, opts...)
+	return pool, &created
+}
+
+func TestPoolReuse(t *testing.T) {
+	is := is.New(t)
+	pool, created := newTestPool()
+	v, err := pool.Get(context.Background())
+	is.NoErr(err)
+	pool.Put(v)
+	v2, err := pool.Get(context.Background())
+	is.NoErr(err)
+	is.Equal(v, v2)
+	is.Equal(*created, 1)
+	is.Equal(pool.Stats().InUse(), 1)
+}
+
+func TestPoolValidate(t *testing.T) {
+	is := is.New(t)
+	destroyed := []int{}
+	pool, _ := newTestPool()
+	pool.SetValidate(func(v int) bool { return v != 1 }).
+		SetOnDestroy(func(v int) { destroyed = append(destroyed, v) })
+	v, _ := pool.Get(context.Background())
+	pool.Put(v)
+	v, err := pool.Get(context.Background())
+	is.NoErr(err)
+	is.Equal(v, 2)
+	is.Equal(destroyed, []int{1})
+}
+
+func TestPoolMaxIdle(t *testing.T) {
+	is := is.New(t)
+	pool, _ := newTestPool(structures.MaxIdlePoolOpt(1))
+	a, _ := pool.Get(context.Background())
+	b, _ := pool.Get(context.Background())
+	pool.Put(a)
+	pool.Put(b)
+	stats := pool.Stats()
+	is.Equal(stats.Idle(), 1)
+	is.Equal(stats.Total(), 1)
+	is.Equal(stats.Destroyed(), 1)
+}
+
+func TestPoolWaitersFIFO(t *testing.T) {
+	is := is.New(t)
+	pool, _ := newTestPool(structures.MaxTotalPoolOpt(1))
+	v, _ := pool.Get(context.Background())
+
+	order := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		go func(i int) {
+			got, err := pool.Get(context.Background())
+			is.NoErr(err)
+			order <- i
+			pool.Put(got)
+		}(i)
+		for pool.Stats().Waiting() != i {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	pool.Put(v)
+	is.Equal(<-order, 1)
+	is.Equal(<-order, 2)
+}
+
+func TestPoolGetCanceled(t *testing.T) {
+	is := is.New(t)
+	pool, _ := newTestPool(structures.MaxTotalPoolOpt(1))
+	_, _ = pool.Get(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := pool.Get(ctx)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.Equal(pool.Stats().Waiting(), 0)
+}
+
+func TestPoolClose(t *testing.T) {
+	is := is.New(t)
+	pool, _ := newTestPool(structures.MaxTotalPoolOpt(1))
+	v, _ := pool.Get(context.Background())
+	errCh := make(chan error)
+	go func() {
+		_, err := pool.Get(context.Background())
+		errCh <- err
+	}()
+	for pool.Stats().Waiting() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	pool.Close()
+	is.Equal(<-errCh, structures.ErrPoolClosed)
+	pool.Put(v)
+	is.Equal(pool.Stats().Total(), 0)
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	is := is.New(t)
+	clock := structures.NewFakeClock(time.Now())
+	pool, _ := newTestPool(
+		structures.IdleTimeoutPoolOpt(10*time.Millisecond),
+		structures.ClockPoolOpt(clock),
+	)
+	defer pool.Close()
+	// The evictor re-arms its timer once a sweep is done
+	waitForSweep := func() {
+		for clock.PendingTimers() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitForSweep()
+	v, _ := pool.Get(context.Background())
+	pool.Put(v)
+	clock.Advance(5 * time.Millisecond)
+	waitForSweep()
+	is.Equal(pool.Stats().Idle(), 1)
+	clock.Advance(5 * time.Millisecond)
+	waitForSweep()
+	is.Equal(pool.Stats().Idle(), 0)
+	is.Equal(pool.Stats().Total(), 0)
+}
@@ -0,0 +1,403 @@
+package structures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"sync"
+)
+
+// RingBufferMode decides what Push does when the buffer is full.
+type RingBufferMode int
+
+const (
+	// RingBufferOverwrite drops the oldest element to make room.
+	RingBufferOverwrite RingBufferMode = iota
+	// RingBufferReject refuses the new element.
+	RingBufferReject
+	// RingBufferBlock waits for room. Only SafeRingBuffer can block, a
+	// RingBuffer in this mode rejects like RingBufferReject.
+	RingBufferBlock
+)
+
+type RingBufferOpts struct {
+	Mode RingBufferMode
+}
+
+type RingBufferOpt func(*RingBufferOpts)
+
+func NewRingBufferOptions(opts ...RingBufferOpt) *RingBufferOpts {
+	defaults := &RingBufferOpts{
+		Mode: RingBufferOverwrite,
+	}
+	for _, o := range opts {
+		o(defaults)
+	}
+	return defaults
+}
+
+func RejectRingBufferOpt() RingBufferOpt {
+	return func(opts *RingBufferOpts) {
+		opts.Mode = RingBufferReject
+	}
+}
+
+// BlockRingBufferOpt makes Push wait for room. Only SafeRingBuffer can
+// block, a RingBuffer rejects instead.
+func BlockRingBufferOpt() RingBufferOpt {
+	return func(opts *RingBufferOpts) {
+		opts.Mode = RingBufferBlock
+	}
+}
+
+// RingBuffer is a fixed-capacity FIFO backed by a slice. It doesn't allocate
+// after construction. It is not safe for concurrent use, see SafeRingBuffer.
+type RingBuffer[T any] struct {
+	buf  []T
+	head int
+	size int
+	opts *RingBufferOpts
+}
+
+// NewRingBuffer returns an empty buffer holding up to capacity elements. A
+// capacity below 1 is raised to 1. RingBufferBlock can't block without a
+// lock, so a RingBuffer built with BlockRingBufferOpt rejects like
+// RejectRingBufferOpt; use NewSafeRingBuffer to block.
+func NewRingBuffer[T any](capacity int, opts ...RingBufferOpt) *RingBuffer[T] {
+	return &RingBuffer[T]{
+		buf:  make([]T, max(capacity, 1)),
+		opts: NewRingBufferOptions(opts...),
+	}
+}
+
+// Push adds an element to the end of the buffer. When full it overwrites
+// the oldest element or returns false, depending on the mode.
+// time-complexity: O(1)
+func (r *RingBuffer[T]) Push(val T) bool {
+	if r.IsFull() {
+		if r.opts.Mode != RingBufferOverwrite {
+			return false
+		}
+		r.buf[r.head] = val
+		r.head = r.index(1)
+		return true
+	}
+	r.buf[r.index(r.size)] = val
+	r.size++
+	return true
+}
+
+// Pop removes and returns the oldest element. It returns false if the buffer is empty.
+// time-complexity: O(1)
+func (r *RingBuffer[T]) Pop() (val T, ok bool) {
+	if r.IsEmpty() {
+		return
+	}
+	var zero T
+	val = r.buf[r.head]
+	r.buf[r.head] = zero
+	r.head = r.index(1)
+	r.size--
+	return val, true
+}
+
+// Peek returns the oldest element. It returns false if the buffer is empty.
+// time-complexity: O(1)
+func (r *RingBuffer[T]) Peek() (val T, ok bool) {
+	if r.IsEmpty() {
+		return
+	}
+	return r.buf[r.head], true
+}
+
+// PeekLast returns the newest element. It returns false if the buffer is empty.
+// time-complexity: O(1)
+func (r *RingBuffer[T]) PeekLast() (val T, ok bool) {
+	if r.IsEmpty() {
+		return
+	}
+	return r.buf[r.index(r.size-1)], true
+}
+
+func (r *RingBuffer[T]) Len() int {
+	return r.size
+}
+
+func (r *RingBuffer[T]) Cap() int {
+	return len(r.buf)
+}
+
+func (r *RingBuffer[T]) IsEmpty() bool {
+	return r.size == 0
+}
+
+func (r *RingBuffer[T]) IsFull() bool {
+	return r.size == len(r.buf)
+}
+
+// Clear removes all elements.
+// time-complexity: O(n)
+func (r *RingBuffer[T]) Clear() {
+	clear(r.buf)
+	r.head = 0
+	r.size = 0
+}
+
+// Vals returns the elements oldest to newest.
+// time-complexity: O(n)
+func (r *RingBuffer[T]) Vals() []T {
+	vals := make([]T, 0, r.size)
+	for _, v := range r.All() {
+		vals = append(vals, v)
+	}
+	return vals
+}
+
+// All returns an iterator over the elements oldest to newest. The buffer
+// must not be modified while iterating.
+// time-complexity: O(n)
+func (r *RingBuffer[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i := 0; i < r.size; i++ {
+			if !yield(i, r.buf[r.index(i)]) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON encodes the buffer as a JSON array, oldest to newest. The
+// capacity and mode aren't encoded.
+// time-complexity: O(n)
+func (r *RingBuffer[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Vals())
+}
+
+// UnmarshalJSON replaces the buffer contents with the elements of a JSON
+// array, see reset.
+// time-complexity: O(n)
+func (r *RingBuffer[T]) UnmarshalJSON(data []byte) error {
+	var vals []T
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	return r.reset(vals)
+}
+
+// MarshalBinary encodes the buffer with encoding/gob, oldest to newest.
+// time-complexity: O(n)
+func (r *RingBuffer[T]) MarshalBinary() ([]byte, error) {
+	return gobEncode(r.Vals())
+}
+
+// UnmarshalBinary replaces the buffer contents with gob encoded elements.
+// time-complexity: O(n)
+func (r *RingBuffer[T]) UnmarshalBinary(data []byte) error {
+	var vals []T
+	if err := gobDecode(data, &vals); err != nil {
+		return err
+	}
+	return r.reset(vals)
+}
+
+// reset replaces the contents with vals. A zero value buffer gets room for
+// exactly vals. If vals don't fit, the oldest are dropped in
+// RingBufferOverwrite mode and an error is returned otherwise.
+func (r *RingBuffer[T]) reset(vals []T) error {
+	if r.buf == nil {
+		*r = *NewRingBuffer[T](len(vals))
+	}
+	if len(vals) > r.Cap() && r.opts.Mode != RingBufferOverwrite {
+		return fmt.Errorf("ring buffer holds at most %d elements, got %d", r.Cap(), len(vals))
+	}
+	r.Clear()
+	for _, v := range vals {
+		r.Push(v)
+	}
+	return nil
+}
+
+func (r *RingBuffer[T]) index(offset int) int {
+	return (r.head + offset) % len(r.buf)
+}
+
+// SafeRingBuffer is a RingBuffer guarded by a mutex. In RingBufferBlock mode
+// Push waits for room instead of rejecting.
+type SafeRingBuffer[T any] struct {
+	rb       *RingBuffer[T]
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+}
+
+// NewSafeRingBuffer returns an empty buffer holding up to capacity
+// elements. A capacity below 1 is raised to 1.
+func NewSafeRingBuffer[T any](capacity int, opts ...RingBufferOpt) *SafeRingBuffer[T] {
+	s := &SafeRingBuffer[T]{
+		rb: NewRingBuffer[T](capacity, opts...),
+	}
+	s.notEmpty = sync.NewCond(&s.mu)
+	s.notFull = sync.NewCond(&s.mu)
+	return s
+}
+
+// Push adds an element to the end of the buffer. When full it overwrites
+// the oldest element, returns false or blocks, depending on the mode.
+func (s *SafeRingBuffer[T]) Push(val T) bool {
+	if s.rb.opts.Mode == RingBufferBlock {
+		return s.PushWait(context.Background(), val) == nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ok := s.rb.Push(val)
+	if ok {
+		s.notEmpty.Signal()
+	}
+	return ok
+}
+
+// PushWait adds an element to the end of the buffer, waiting for room if
+// it is full, until ctx is done. In RingBufferOverwrite mode it never waits.
+func (s *SafeRingBuffer[T]) PushWait(ctx context.Context, val T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rb.opts.Mode != RingBufferOverwrite {
+		if err := waitCond(ctx, &s.mu, s.notFull, s.rb.IsFull); err != nil {
+			return err
+		}
+	}
+	s.rb.Push(val)
+	s.notEmpty.Signal()
+	return nil
+}
+
+// Pop removes and returns the oldest element. It returns false if the buffer is empty.
+func (s *SafeRingBuffer[T]) Pop() (val T, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, ok = s.rb.Pop()
+	if ok {
+		s.notFull.Signal()
+	}
+	return val, ok
+}
+
+// PopWait removes and returns the oldest element, waiting for one until ctx is done.
+func (s *SafeRingBuffer[T]) PopWait(ctx context.Context) (val T, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = waitCond(ctx, &s.mu, s.notEmpty, s.rb.IsEmpty); err != nil {
+		return val, err
+	}
+	val, _ = s.rb.Pop()
+	s.notFull.Signal()
+	return val, nil
+}
+
+func (s *SafeRingBuffer[T]) Peek() (val T, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rb.Peek()
+}
+
+func (s *SafeRingBuffer[T]) PeekLast() (val T, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rb.PeekLast()
+}
+
+func (s *SafeRingBuffer[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rb.Len()
+}
+
+func (s *SafeRingBuffer[T]) Cap() int {
+	return s.rb.Cap()
+}
+
+func (s *SafeRingBuffer[T]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rb.Clear()
+	s.notFull.Broadcast()
+}
+
+// Vals returns a copy of the elements oldest to newest.
+func (s *SafeRingBuffer[T]) Vals() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rb.Vals()
+}
+
+// MarshalJSON encodes the buffer as a JSON array, oldest to newest.
+func (s *SafeRingBuffer[T]) MarshalJSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rb.MarshalJSON()
+}
+
+// UnmarshalJSON replaces the buffer contents with the elements of a JSON
+// array. It fails if they don't fit and the buffer doesn't overwrite.
+func (s *SafeRingBuffer[T]) UnmarshalJSON(data []byte) error {
+	var vals []T
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	return s.reset(vals)
+}
+
+// MarshalBinary encodes the buffer with encoding/gob, oldest to newest.
+func (s *SafeRingBuffer[T]) MarshalBinary() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rb.MarshalBinary()
+}
+
+// UnmarshalBinary replaces the buffer contents with gob encoded elements.
+func (s *SafeRingBuffer[T]) UnmarshalBinary(data []byte) error {
+	var vals []T
+	if err := gobDecode(data, &vals); err != nil {
+		return err
+	}
+	return s.reset(vals)
+}
+
+func (s *SafeRingBuffer[T]) reset(vals []T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rb == nil {
+		s.rb = &RingBuffer[T]{}
+		s.notEmpty = sync.NewCond(&s.mu)
+		s.notFull = sync.NewCond(&s.mu)
+	}
+	if err := s.rb.reset(vals); err != nil {
+		return err
+	}
+	s.notEmpty.Broadcast()
+	s.notFull.Broadcast()
+	return nil
+}
+
+// waitCond blocks on cond while blocked returns true. It must be called
+// with mu held. Canceling ctx wakes every waiter so each can check its
+// context.
+func waitCond(ctx context.Context, mu sync.Locker, cond *sync.Cond, blocked func() bool) error {
+	if !blocked() {
+		return nil
+	}
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		cond.Broadcast()
+	})
+	defer stop()
+	for blocked() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cond.Wait()
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package structures_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestRingBufferOverwrite(t *testing.T) {
+	is := is.New(t)
+	rb := structures.NewRingBuffer[int](3)
+	for i := 1; i <= 5; i++ {
+		is.True(rb.Push(i))
+	}
+	is.Equal(rb.Vals(), []int{3, 4, 5})
+	first, _ := rb.Peek()
+	last, _ := rb.PeekLast()
+	is.Equal(first, 3)
+	is.Equal(last, 5)
+	v, ok := rb.Pop()
+	is.True(ok)
+	is.Equal(v, 3)
+	is.Equal(rb.Len(), 2)
+}
+
+func TestRingBufferReject(t *testing.T) {
+	is := is.New(t)
+	rb := structures.NewRingBuffer[int](2, structures.RejectRingBufferOpt())
+	is.True(rb.Push(1))
+	is.True(rb.Push(2))
+	is.True(!rb.Push(3))
+	is.Equal(rb.Vals(), []int{1, 2})
+	rb.Clear()
+	_, ok := rb.Pop()
+	is.True(!ok)
+}
+
+func TestSafeRingBufferBlock(t *testing.T) {
+	is := is.New(t)
+	rb := structures.NewSafeRingBuffer[int](1, structures.BlockRingBufferOpt())
+	is.True(rb.Push(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	is.True(errors.Is(rb.PushWait(ctx, 2), context.DeadlineExceeded))
+
+	pushed := make(chan struct{})
+	go func() {
+		rb.Push(2)
+		close(pushed)
+	}()
+	v, err := rb.PopWait(context.Background())
+	is.NoErr(err)
+	is.Equal(v, 1)
+	<-pushed
+	v, err = rb.PopWait(context.Background())
+	is.NoErr(err)
+	is.Equal(v, 2)
+}
+
+func BenchmarkRingBufferPushPop(b *testing.B) {
+	rb := structures.NewRingBuffer[int](1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rb.Push(i)
+		if rb.IsFull() {
+			rb.Pop()
+		}
+	}
+}
+
+func BenchmarkSafeRingBufferPushPop(b *testing.B) {
+	rb := structures.NewSafeRingBuffer[int](1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rb.Push(i)
+		rb.Pop()
+	}
+}
+
+func BenchmarkCircularLinkedListPushPop(b *testing.B) {
+	list := structures.NewCircularLinkedList[int]()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		list.AddLast(i)
+		if list.Size() == 1024 {
+			list.RemoveFirst()
+		}
+	}
+}
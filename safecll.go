@@ -0,0 +1,352 @@
+package structures
+
+import (
+	"encoding/json"
+	"iter"
+	"sync"
+)
+
+// SafeCircularLinkedList is a CircularLinkedList guarded by a RWMutex. On top
+// of the CircularLinkedList API it has compound operations that run under a
+// single lock, like RotateAndGet and RemoveFirstIf.
+type SafeCircularLinkedList[T comparable] struct {
+	mu   sync.RWMutex
+	list CircularLinkedList[T]
+}
+
+// NewSafeCircularLinkedList constructs and returns an empty thread-safe circularly linked-list.
+// time-complexity: O(1)
+func NewSafeCircularLinkedList[T comparable]() *SafeCircularLinkedList[T] {
+	return &SafeCircularLinkedList[T]{}
+}
+
+// SetNodePool makes the list take nodes from and return removed nodes to pool.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) SetNodePool(pool *NodePool[T]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list.SetNodePool(pool)
+}
+
+// Size returns the number of nodes in the list.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) Size() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.Size()
+}
+
+// IsEmpty returns true if the linked-list doesn't contain any nodes.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) IsEmpty() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.IsEmpty()
+}
+
+// First returns the first element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) First() (data T, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.First()
+}
+
+// Last returns the last element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) Last() (data T, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.Last()
+}
+
+// Rotate rotates the list. It moves the first element to the end.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) Rotate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list.Rotate()
+}
+
+// RotateAndGet returns the first element and moves it to the end in one
+// step, so concurrent callers never get the same element twice in a row. It
+// returns false if the list is empty.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) RotateAndGet() (data T, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok = c.list.First()
+	c.list.Rotate()
+	return data, ok
+}
+
+// RotateN rotates the list n times. A negative n rotates backwards.
+// time-complexity: O(n mod Size)
+func (c *SafeCircularLinkedList[T]) RotateN(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list.RotateN(n)
+}
+
+// Add adds a new node to the beginning of the list.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) Add(data T) {
+	c.AddFirst(data)
+}
+
+// AddFirst adds a new node to the beginning of the list.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) AddFirst(data T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list.AddFirst(data)
+}
+
+// AddLast adds a new node to the end of the list.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) AddLast(data T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list.AddLast(data)
+}
+
+// InsertAt inserts a new node so that it ends up at index i. It returns false if i is out of range [0, Size].
+// time-complexity: O(i)
+func (c *SafeCircularLinkedList[T]) InsertAt(i int, data T) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list.InsertAt(i, data)
+}
+
+// InsertAfter inserts a new node after the first occurrence of val. It returns false if val isn't in the list.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) InsertAfter(val T, data T) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list.InsertAfter(val, data)
+}
+
+// RemoveFirst removes and returns the first element of the list. It returns false if the list is empty.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) RemoveFirst() (val T, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list.RemoveFirst()
+}
+
+// RemoveFirstIf removes and returns the first element if pred returns true
+// for it. Checking and removing happen under one lock.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) RemoveFirstIf(pred func(T) bool) (val T, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	first, ok := c.list.First()
+	if !ok || !pred(first) {
+		return val, false
+	}
+	return c.list.RemoveFirst()
+}
+
+// RemoveLast removes and returns the last element of the list. It returns false if the list empty.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) RemoveLast() (val T, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list.RemoveLast()
+}
+
+// Remove removes the first occurrence of val.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) Remove(val T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list.Remove(val)
+}
+
+// RemoveAll removes every occurrence of val and returns how many nodes were removed.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) RemoveAll(val T) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list.RemoveAll(val)
+}
+
+// RemoveFunc removes every element for which pred returns true and returns how many nodes were removed.
+// pred runs under the lock and must not call back into the list.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) RemoveFunc(pred func(T) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list.RemoveFunc(pred)
+}
+
+// At returns the element at index i. It returns false if i is out of range.
+// time-complexity: O(i)
+func (c *SafeCircularLinkedList[T]) At(i int) (data T, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.At(i)
+}
+
+// IndexOf returns the index of the first occurrence of val, or -1 if it isn't in the list.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) IndexOf(val T) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.IndexOf(val)
+}
+
+// Contains returns true if val is in the list.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) Contains(val T) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.Contains(val)
+}
+
+// Concat moves all nodes of other to the end of the list, leaving other
+// empty. Both lists stay locked for the whole move, like with Splice.
+// time-complexity: O(1)
+func (c *SafeCircularLinkedList[T]) Concat(other *SafeCircularLinkedList[T]) {
+	if other == c {
+		return
+	}
+	unlock := lockPair(&c.mu, &other.mu)
+	defer unlock()
+	c.list.Concat(&other.list)
+}
+
+// Splice moves all nodes of other into the list so that other's first element ends up at index i,
+// leaving other empty. It returns false, leaving other untouched, if i is out of range [0, Size].
+// Both lists stay locked for the whole move, so no other goroutine sees it half done.
+// time-complexity: O(i)
+func (c *SafeCircularLinkedList[T]) Splice(i int, other *SafeCircularLinkedList[T]) bool {
+	if other == c {
+		return false
+	}
+	unlock := lockPair(&c.mu, &other.mu)
+	defer unlock()
+	return c.list.Splice(i, &other.list)
+}
+
+// SplitAt splits the list into the elements before index i and the elements from index i on,
+// leaving the list empty. i is clamped to [0, Size].
+// time-complexity: O(i)
+func (c *SafeCircularLinkedList[T]) SplitAt(i int) (left *SafeCircularLinkedList[T], right *SafeCircularLinkedList[T]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, r := c.list.SplitAt(i)
+	return &SafeCircularLinkedList[T]{list: l}, &SafeCircularLinkedList[T]{list: r}
+}
+
+// Vals returns a copy of the elements of the list in order.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) Vals() []T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.Vals()
+}
+
+// All returns an iterator over a snapshot of the list taken when the
+// iteration starts. The loop body may modify the list.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i, v := range c.Vals() {
+			if !yield(i, v) {
+				return
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over a snapshot of the list, last to first.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		vals := c.Vals()
+		for i := len(vals) - 1; i >= 0; i-- {
+			if !yield(i, vals[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over a snapshot of the elements of the list.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range c.Vals() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// String returns the string representation of the list.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) String() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.String()
+}
+
+// MarshalJSON encodes the list as a JSON array, first to last.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) MarshalJSON() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.MarshalJSON()
+}
+
+// UnmarshalJSON replaces the list with the elements of a JSON array.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) UnmarshalJSON(data []byte) error {
+	var vals []T
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list.reset(vals)
+	return nil
+}
+
+// MarshalBinary encodes the list with encoding/gob.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) MarshalBinary() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.MarshalBinary()
+}
+
+// UnmarshalBinary replaces the list with gob encoded elements.
+// time-complexity: O(n)
+func (c *SafeCircularLinkedList[T]) UnmarshalBinary(data []byte) error {
+	var vals []T
+	if err := gobDecode(data, &vals); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list.reset(vals)
+	return nil
+}
+
+// pairMu is held while two lists are locked together, so two such
+// operations on the same lists in opposite order can't deadlock.
+var pairMu sync.Mutex
+
+// lockPair locks a and b and returns a func unlocking both.
+func lockPair(a, b *sync.RWMutex) (unlock func()) {
+	pairMu.Lock()
+	defer pairMu.Unlock()
+	a.Lock()
+	b.Lock()
+	return func() {
+		b.Unlock()
+		a.Unlock()
+	}
+}
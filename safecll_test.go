@@ -0,0 +1,121 @@
+package structures_test
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestSafeCircularLinkedListRotateAndGet(t *testing.T) {
+	is := is.New(t)
+	list := structures.NewSafeCircularLinkedList[int]()
+	_, ok := list.RotateAndGet()
+	is.True(!ok)
+
+	const n, rounds = 4, 400
+	for i := range n {
+		list.AddLast(i)
+	}
+	counts := make([]int, n)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range rounds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, ok := list.RotateAndGet()
+			if !ok {
+				return
+			}
+			mu.Lock()
+			counts[v]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	for _, c := range counts {
+		is.Equal(c, rounds/n) // every element handed out equally often
+	}
+	is.Equal(list.Size(), n)
+}
+
+func TestSafeCircularLinkedListRemoveFirstIf(t *testing.T) {
+	is := is.New(t)
+	list := structures.NewSafeCircularLinkedList[int]()
+	list.AddLast(1)
+	list.AddLast(2)
+
+	_, ok := list.RemoveFirstIf(func(v int) bool { return v == 2 })
+	is.True(!ok)
+	v, ok := list.RemoveFirstIf(func(v int) bool { return v == 1 })
+	is.True(ok)
+	is.Equal(v, 1)
+	is.Equal(list.Vals(), []int{2})
+}
+
+func TestSafeCircularLinkedListConcat(t *testing.T) {
+	is := is.New(t)
+	a := structures.NewSafeCircularLinkedList[int]()
+	b := structures.NewSafeCircularLinkedList[int]()
+	a.AddLast(1)
+	b.AddLast(2)
+	b.AddLast(3)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); a.Concat(b) }()
+	go func() { defer wg.Done(); b.Concat(a) }()
+	wg.Wait()
+	is.Equal(a.Size()+b.Size(), 3)
+
+	is.True(!a.Splice(10, b))
+	is.Equal(a.Size()+b.Size(), 3)
+
+	// Splices in opposite directions lock both lists without deadlocking
+	wg.Add(200)
+	for range 100 {
+		go func() { defer wg.Done(); a.Splice(0, b) }()
+		go func() { defer wg.Done(); b.Splice(0, a) }()
+	}
+	wg.Wait()
+	is.Equal(a.Size()+b.Size(), 3)
+}
+
+func TestSafeCircularLinkedListSpliceOutOfRange(t *testing.T) {
+	is := is.New(t)
+	a := structures.NewSafeCircularLinkedList[int]()
+	b := structures.NewSafeCircularLinkedList[int]()
+	b.AddLast(1)
+	b.AddLast(2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 1000 {
+			b.AddLast(10 + i)
+		}
+	}()
+	for range 1000 {
+		is.True(!a.Splice(5, b))
+	}
+	<-done
+	vals := b.Vals()
+	is.Equal(vals[:2], []int{1, 2}) // other is never reordered
+	is.Equal(len(vals), 1002)
+}
+
+func TestSafeCircularLinkedListJSON(t *testing.T) {
+	is := is.New(t)
+	list := structures.NewSafeCircularLinkedList[string]()
+	list.AddLast("a")
+	list.AddLast("b")
+	data, err := json.Marshal(list)
+	is.NoErr(err)
+	is.Equal(string(data), `["a","b"]`)
+
+	var decoded structures.SafeCircularLinkedList[string]
+	is.NoErr(json.Unmarshal(data, &decoded))
+	is.Equal(decoded.Vals(), []string{"a", "b"})
+}
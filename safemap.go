@@ -1,6 +1,10 @@
 package structures
 
-import "sync"
+import (
+	"encoding/json"
+	"iter"
+	"sync"
+)
 
 type SafeMap[K comparable, V any] struct {
 	mu   sync.RWMutex
@@ -91,3 +95,73 @@ func (s *SafeMap[K, V]) Data() map[K]V {
 	defer s.mu.RUnlock()
 	return s.data
 }
+
+// All returns an iterator over the key-value pairs of the map. Range over it
+// with a single variable to iterate keys only. Keys are snapshotted when the
+// iteration starts and each value is read under a short read lock, so unlike
+// ForEach the loop body may call other SafeMap methods. Keys deleted during
+// the iteration are skipped.
+func (s *SafeMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, k := range s.Keys() {
+			v, ok := s.Get(k)
+			if !ok {
+				continue
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the values of the map with the same
+// snapshot semantics as All.
+func (s *SafeMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range s.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON encodes the map as a JSON object. K must be a string, an
+// integer or implement encoding.TextMarshaler, like for a plain map.
+func (s *SafeMap[K, V]) MarshalJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.Marshal(s.data)
+}
+
+// UnmarshalJSON replaces the data with the entries of a JSON object.
+func (s *SafeMap[K, V]) UnmarshalJSON(data []byte) error {
+	m := map[K]V{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = m
+	return nil
+}
+
+// MarshalBinary encodes the map with encoding/gob.
+func (s *SafeMap[K, V]) MarshalBinary() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return gobEncode(s.data)
+}
+
+// UnmarshalBinary replaces the data with gob encoded entries.
+func (s *SafeMap[K, V]) UnmarshalBinary(data []byte) error {
+	m := map[K]V{}
+	if err := gobDecode(data, &m); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = m
+	return nil
+}
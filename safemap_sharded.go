@@ -0,0 +1,214 @@
+package structures
+
+import (
+	"encoding/json"
+	"hash/maphash"
+	"iter"
+	"runtime"
+)
+
+// ShardedSafeMap is a SafeMap split into shards, each with its own lock, so
+// goroutines working on different keys rarely contend. Keys are assigned to
+// shards by a hash function, maphash.Comparable by default.
+type ShardedSafeMap[K comparable, V any] struct {
+	shards []*SafeMap[K, V]
+	hash   func(K) uint64
+}
+
+// NewShardedSafeMap returns a map with the given number of shards, rounded up
+// to a power of two. shards <= 0 picks 4 per CPU.
+func NewShardedSafeMap[K comparable, V any](shards int) *ShardedSafeMap[K, V] {
+	s := &ShardedSafeMap[K, V]{
+		shards: make([]*SafeMap[K, V], shardCount(shards)),
+		hash:   defaultHasher[K](),
+	}
+	for i := range s.shards {
+		s.shards[i] = NewSafeMap[K, V]()
+	}
+	return s
+}
+
+// SetHasher replaces the function that assigns keys to shards. Entries
+// already in the map are moved to their new shards, so it is cheapest to
+// call before the map is used. It must not run concurrently with other
+// methods.
+func (s *ShardedSafeMap[K, V]) SetHasher(hash func(K) uint64) *ShardedSafeMap[K, V] {
+	old := s.shards
+	s.hash = hash
+	s.shards = make([]*SafeMap[K, V], len(old))
+	for i := range s.shards {
+		s.shards[i] = NewSafeMap[K, V]()
+	}
+	for _, shard := range old {
+		for k, v := range shard.data {
+			s.shard(k).data[k] = v
+		}
+	}
+	return s
+}
+
+func (s *ShardedSafeMap[K, V]) Shards() int {
+	return len(s.shards)
+}
+
+func (s *ShardedSafeMap[K, V]) Set(k K, v V) {
+	s.shard(k).Set(k, v)
+}
+
+func (s *ShardedSafeMap[K, V]) Get(k K) (V, bool) {
+	return s.shard(k).Get(k)
+}
+
+func (s *ShardedSafeMap[K, V]) MustGet(k K) V {
+	return s.shard(k).MustGet(k)
+}
+
+func (s *ShardedSafeMap[K, V]) Delete(k K) {
+	s.shard(k).Delete(k)
+}
+
+func (s *ShardedSafeMap[K, V]) Has(k K) bool {
+	return s.shard(k).Has(k)
+}
+
+// Len returns the number of entries. Shards are counted one after another,
+// so with concurrent writers the result is approximate.
+func (s *ShardedSafeMap[K, V]) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// ForEach calls f for every entry, holding one shard's read lock at a time.
+func (s *ShardedSafeMap[K, V]) ForEach(f func(K, V)) {
+	for _, shard := range s.shards {
+		shard.ForEach(f)
+	}
+}
+
+// ForEachWithBreak calls f for each entry and stops when f returns true.
+func (s *ShardedSafeMap[K, V]) ForEachWithBreak(f func(K, V) bool) {
+	stop := false
+	for _, shard := range s.shards {
+		shard.ForEachWithBreak(func(k K, v V) bool {
+			stop = f(k, v)
+			return stop
+		})
+		if stop {
+			return
+		}
+	}
+}
+
+func (s *ShardedSafeMap[K, V]) Keys() []K {
+	keys := make([]K, 0, s.Len())
+	for _, shard := range s.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+	return keys
+}
+
+// Data returns a copy of all entries merged into one map. Unlike
+// SafeMap.Data it isn't the live map.
+func (s *ShardedSafeMap[K, V]) Data() map[K]V {
+	data := make(map[K]V, s.Len())
+	s.ForEach(func(k K, v V) {
+		data[k] = v
+	})
+	return data
+}
+
+// All returns an iterator over the key-value pairs of the map with the same
+// snapshot semantics as SafeMap.All, one shard at a time.
+func (s *ShardedSafeMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, shard := range s.shards {
+			for k, v := range shard.All() {
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the values of the map.
+func (s *ShardedSafeMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range s.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON encodes the map as a single JSON object, like SafeMap.
+func (s *ShardedSafeMap[K, V]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Data())
+}
+
+// UnmarshalJSON replaces the data with the entries of a JSON object.
+func (s *ShardedSafeMap[K, V]) UnmarshalJSON(data []byte) error {
+	m := map[K]V{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	s.reset(m)
+	return nil
+}
+
+// MarshalBinary encodes the map with encoding/gob.
+func (s *ShardedSafeMap[K, V]) MarshalBinary() ([]byte, error) {
+	return gobEncode(s.Data())
+}
+
+// UnmarshalBinary replaces the data with gob encoded entries.
+func (s *ShardedSafeMap[K, V]) UnmarshalBinary(data []byte) error {
+	m := map[K]V{}
+	if err := gobDecode(data, &m); err != nil {
+		return err
+	}
+	s.reset(m)
+	return nil
+}
+
+func (s *ShardedSafeMap[K, V]) reset(m map[K]V) {
+	if s.shards == nil {
+		*s = *NewShardedSafeMap[K, V](0)
+	}
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		shard.data = map[K]V{}
+		shard.mu.Unlock()
+	}
+	for k, v := range m {
+		s.Set(k, v)
+	}
+}
+
+func (s *ShardedSafeMap[K, V]) shard(k K) *SafeMap[K, V] {
+	return s.shards[s.hash(k)&uint64(len(s.shards)-1)]
+}
+
+// shardCount rounds n up to a power of two, picking 4 shards per CPU for n <= 0.
+func shardCount(n int) int {
+	if n <= 0 {
+		n = 4 * runtime.GOMAXPROCS(0)
+	}
+	count := 1
+	for count < n {
+		count <<= 1
+	}
+	return count
+}
+
+// defaultHasher hashes keys with maphash.Comparable and a random seed.
+func defaultHasher[K comparable]() func(K) uint64 {
+	seed := maphash.MakeSeed()
+	return func(k K) uint64 {
+		return maphash.Comparable(seed, k)
+	}
+}
@@ -0,0 +1,92 @@
+package structures_test
+
+import (
+	"encoding/json"
+	"maps"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestShardedSafeMap(t *testing.T) {
+	is := is.New(t)
+	m := structures.NewShardedSafeMap[int, int](5)
+	is.Equal(m.Shards(), 8)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				m.Set(g*100+i, i)
+			}
+		}()
+	}
+	wg.Wait()
+	is.Equal(m.Len(), 800)
+	is.Equal(m.MustGet(305), 5)
+	m.Delete(305)
+	is.True(!m.Has(305))
+	is.Equal(len(m.Keys()), 799)
+	is.Equal(maps.Collect(m.All()), m.Data())
+
+	m.SetHasher(func(k int) uint64 { return uint64(k) })
+	is.Equal(m.Len(), 799)
+	v, ok := m.Get(42)
+	is.True(ok)
+	is.Equal(v, 42)
+
+	data, err := json.Marshal(m)
+	is.NoErr(err)
+	var decoded structures.ShardedSafeMap[int, int]
+	is.NoErr(json.Unmarshal(data, &decoded))
+	is.Equal(decoded.Data(), m.Data())
+}
+
+// BenchmarkMapsParallel compares read-mostly throughput of SafeMap,
+// ShardedSafeMap and sync.Map with every CPU hitting the map at once.
+func BenchmarkMapsParallel(b *testing.B) {
+	const keys = 1 << 16
+	type mapLike struct {
+		get func(string) bool
+		set func(string, int)
+	}
+	safe := structures.NewSafeMap[string, int]()
+	sharded := structures.NewShardedSafeMap[string, int](0)
+	var syncMap sync.Map
+	impls := []struct {
+		name string
+		m    mapLike
+	}{
+		{"SafeMap", mapLike{func(k string) bool { _, ok := safe.Get(k); return ok }, safe.Set}},
+		{"ShardedSafeMap", mapLike{func(k string) bool { _, ok := sharded.Get(k); return ok }, sharded.Set}},
+		{"sync.Map", mapLike{func(k string) bool { _, ok := syncMap.Load(k); return ok }, func(k string, v int) { syncMap.Store(k, v) }}},
+	}
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+	}
+	for _, impl := range impls {
+		for i, k := range names {
+			impl.m.set(k, i)
+		}
+		b.Run(impl.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.IntN(keys)
+				for pb.Next() {
+					k := names[(i*7919)&(keys-1)]
+					if i%10 == 0 {
+						impl.m.set(k, i)
+					} else {
+						impl.m.get(k)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
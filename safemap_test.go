@@ -0,0 +1,32 @@
+package structures_test
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/stevo-go-utils/structures"
+)
+
+func TestSafeMapAll(t *testing.T) {
+	is := is.New(t)
+	safeMap := structures.NewSafeMap(map[string]int{"a": 1, "b": 2, "c": 3})
+	// The loop body may write to the map without deadlocking
+	for k, v := range safeMap.All() {
+		safeMap.Set(k, v*10)
+	}
+	is.Equal(maps.Collect(safeMap.All()), map[string]int{"a": 10, "b": 20, "c": 30})
+	vals := slices.Sorted(safeMap.Values())
+	is.Equal(vals, []int{10, 20, 30})
+}
+
+func TestSetAll(t *testing.T) {
+	is := is.New(t)
+	set := structures.NewSet(1, 2, 3)
+	for item := range set.All() {
+		set.Delete(item)
+	}
+	is.Equal(set.Size(), 0)
+	is.Equal(len(slices.Collect(set.All())), 0)
+}
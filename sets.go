@@ -1,6 +1,10 @@
 package structures
 
-import "sync"
+import (
+	"encoding/json"
+	"iter"
+	"sync"
+)
 
 type Set[T comparable] struct {
 	items map[T]bool
@@ -76,3 +80,54 @@ func (s *Set[T]) Size() int {
 	defer s.lock.RUnlock()
 	return len(s.items)
 }
+
+// All returns an iterator over the items of the Set. Items are snapshotted
+// when the iteration starts and items deleted during the iteration are
+// skipped. The loop body may call other Set methods.
+func (s *Set[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, item := range s.Vals() {
+			if !s.Has(item) {
+				continue
+			}
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON encodes the Set as a JSON array
+func (s *Set[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Vals())
+}
+
+// UnmarshalJSON replaces the items of the Set with the items of a JSON array
+func (s *Set[T]) UnmarshalJSON(data []byte) error {
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	s.reset(items)
+	return nil
+}
+
+// MarshalBinary encodes the Set with encoding/gob
+func (s *Set[T]) MarshalBinary() ([]byte, error) {
+	return gobEncode(s.Vals())
+}
+
+// UnmarshalBinary replaces the items of the Set with gob encoded items
+func (s *Set[T]) UnmarshalBinary(data []byte) error {
+	var items []T
+	if err := gobDecode(data, &items); err != nil {
+		return err
+	}
+	s.reset(items)
+	return nil
+}
+
+func (s *Set[T]) reset(items []T) {
+	s.Clear()
+	s.Add(items...)
+}